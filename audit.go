@@ -0,0 +1,166 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// AuditEntry records the issuance of a certificate, see WithAuditLog. It is
+// written as a line of JSON.
+type AuditEntry struct {
+	Timestamp         time.Time `json:"timestamp"`
+	SerialNumber      string    `json:"serial_number"` // In decimal
+	Subject           string    `json:"subject"`
+	SANs              []string  `json:"sans"` // In the format of ExtractSANs
+	KeyType           string    `json:"key_type"`
+	KeyBits           int       `json:"key_bits"`
+	NotBefore         time.Time `json:"not_before"`
+	NotAfter          time.Time `json:"not_after"`
+	IssuerFingerprint string    `json:"issuer_fingerprint,omitempty"` // SHA-256, see FingerprintSHA256
+}
+
+// String formats the entry for humans, on a single line
+func (e AuditEntry) String() string {
+	issuer := e.IssuerFingerprint
+	if issuer == "" {
+		issuer = "unknown"
+	}
+
+	return fmt.Sprintf("%s issued serial %s to %q, SANs [%s], %s %d bits, valid %s to %s, issuer %s",
+		e.Timestamp.Format(time.RFC3339), e.SerialNumber, e.Subject, strings.Join(e.SANs, " "),
+		e.KeyType, e.KeyBits, e.NotBefore.Format(time.RFC3339), e.NotAfter.Format(time.RFC3339), issuer)
+}
+
+// WithAuditLog writes an AuditEntry, as a line of JSON, to w for every
+// certificate issued with the options, including the CAs generated along with
+// a leaf. Issuance fails if the entry cannot be written. Each entry is written
+// with a single Write call, so a writer shared between goroutines only needs
+// to make its Write calls safe for concurrent use, as os.File does.
+func WithAuditLog(w io.Writer) Option {
+	return func(c *certConfig) {
+		c.auditLog = w
+	}
+}
+
+// NewAuditedCertStore wraps a certificate store so that every certificate
+// stored in it is recorded in the audit log w, see WithAuditLog. A certificate
+// the underlying store fails to save is not recorded. The issuer
+// fingerprint is only known when the issuer is part of the stored chain, or
+// the certificate is self-signed.
+func NewAuditedCertStore(store CertStore, w io.Writer) CertStore {
+	return &auditedCertStore{CertStore: store, w: w}
+}
+
+// A store recording the certificates it saves
+type auditedCertStore struct {
+	CertStore
+	w io.Writer
+}
+
+// Store saves the certificate in the underlying store, then records it
+func (s *auditedCertStore) Store(name string, cert tls.Certificate) error {
+	leaf, err := leafCertificate(cert)
+	if err != nil {
+		return err
+	}
+
+	var issuer *x509.Certificate
+	if leaf.CheckSignatureFrom(leaf) == nil {
+		issuer = leaf
+	} else if len(cert.Certificate) > 1 {
+		issuer, _ = x509.ParseCertificate(cert.Certificate[1])
+	}
+
+	// Only record certificates that were actually stored
+	if err := s.CertStore.Store(name, cert); err != nil {
+		return err
+	}
+
+	return writeAuditEntry(s.w, leaf, issuer)
+}
+
+// Record a newly issued PEM-encoded certificate in the audit log, if any. A
+// nil issuer means the certificate is self-signed.
+func auditCertPEM(w io.Writer, certPEM []byte, issuer *x509.Certificate) error {
+	if w == nil {
+		return nil
+	}
+
+	cert, err := PEMToCertificate(certPEM)
+	if err != nil {
+		return err
+	}
+
+	if issuer == nil {
+		issuer = cert
+	}
+
+	return writeAuditEntry(w, cert, issuer)
+}
+
+// Describe the issuance of the certificate by the issuer, if known
+func newAuditEntry(cert, issuer *x509.Certificate) (AuditEntry, error) {
+	info, err := InspectCert(tls.Certificate{Certificate: [][]byte{cert.Raw}, Leaf: cert})
+	if err != nil {
+		return AuditEntry{}, err
+	}
+
+	entry := AuditEntry{
+		Timestamp:    time.Now().UTC(),
+		SerialNumber: cert.SerialNumber.String(),
+		Subject:      cert.Subject.String(),
+		SANs:         ExtractSANs(cert),
+		KeyType:      info.KeyType,
+		KeyBits:      info.KeyBits,
+		NotBefore:    cert.NotBefore,
+		NotAfter:     cert.NotAfter,
+	}
+
+	if issuer != nil {
+		entry.IssuerFingerprint = FingerprintSHA256(issuer)
+	}
+
+	return entry, nil
+}
+
+// Write the audit entry of a certificate as a line of JSON, if there is an
+// audit log
+func writeAuditEntry(w io.Writer, cert, issuer *x509.Certificate) error {
+	if w == nil {
+		return nil
+	}
+
+	entry, err := newAuditEntry(cert, issuer)
+	if err != nil {
+		return err
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("privatetls: writing audit log: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,191 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Parse the JSON lines of an audit log
+func readAuditLog(t *testing.T, log *bytes.Buffer) []AuditEntry {
+	t.Helper()
+
+	var entries []AuditEntry
+
+	scanner := bufio.NewScanner(log)
+	for scanner.Scan() {
+		var e AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+		entries = append(entries, e)
+	}
+
+	return entries
+}
+
+func TestWithAuditLog(t *testing.T) {
+	var log bytes.Buffer
+
+	caCert, leafCert, err := NewCAAndLeafCert(WithECDSACurve(elliptic.P256()), WithCommonName("leaf"), WithAuditLog(&log))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	entries := readAuditLog(t, &log)
+
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 audit entries, got %d\n", len(entries))
+	}
+
+	caFingerprint := FingerprintSHA256(caCert.Leaf)
+	ca, leaf := entries[0], entries[1]
+
+	if ca.IssuerFingerprint != caFingerprint || ca.SerialNumber != caCert.Leaf.SerialNumber.String() {
+		t.Errorf("Unexpected CA audit entry %+v\n", ca)
+	}
+
+	if leaf.IssuerFingerprint != caFingerprint || leaf.SerialNumber != leafCert.Leaf.SerialNumber.String() {
+		t.Errorf("Unexpected leaf audit entry %+v\n", leaf)
+	}
+
+	if leaf.Subject != "CN=leaf,O=PrivateTLS" || leaf.KeyType != "ECDSA" || leaf.KeyBits != 256 ||
+		!slices.Contains(leaf.SANs, "dns:localhost") || !leaf.NotAfter.Equal(leafCert.Leaf.NotAfter) ||
+		leaf.Timestamp.IsZero() {
+		t.Errorf("Unexpected leaf audit entry %+v\n", leaf)
+	}
+
+	if s := leaf.String(); !strings.Contains(s, leaf.SerialNumber) || !strings.Contains(s, "ECDSA 256 bits") {
+		t.Errorf("Unexpected audit entry text %q\n", s)
+	}
+}
+
+func TestWithAuditLogCertOptionsAndCSR(t *testing.T) {
+	var log bytes.Buffer
+
+	ca, err := NewCertWithOptions(CertOptions{KeyType: KeyTypeEd25519, AuditLog: &log})
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	csrPEM, err := NewCSR(key, WithDNSNames("service.internal"))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	caX509, caKey, err := parseCertAndSigner(ca)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if _, err := SignCSR(csrPEM, caX509, caKey, WithAuditLog(&log)); err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	entries := readAuditLog(t, &log)
+
+	if len(entries) != 2 || entries[1].IssuerFingerprint != FingerprintSHA256(caX509) ||
+		!slices.Equal(entries[1].SANs, []string{"dns:service.internal"}) {
+		t.Errorf("Unexpected audit entries %+v\n", entries)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestWithAuditLogWriteError(t *testing.T) {
+	if _, err := NewCert(WithEd25519(), WithAuditLog(failingWriter{})); err == nil {
+		t.Error("Expected an error when the audit log cannot be written")
+	}
+}
+
+func TestNewAuditedCertStore(t *testing.T) {
+	var log bytes.Buffer
+	store := NewAuditedCertStore(NewMemoryCertStore(), &log)
+
+	cert, err := NewCert(WithEd25519())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if err := store.Store("localhost", cert); err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if _, ok := store.Load("localhost"); !ok {
+		t.Error("Expected the certificate to be stored")
+	}
+
+	entries := readAuditLog(t, &log)
+
+	if len(entries) != 1 || entries[0].IssuerFingerprint != FingerprintSHA256(cert.Leaf) {
+		t.Errorf("Unexpected audit entries %+v\n", entries)
+	}
+
+	if err := NewAuditedCertStore(NewMemoryCertStore(), failingWriter{}).Store("localhost", cert); err == nil {
+		t.Error("Expected an error when the audit log cannot be written")
+	}
+}
+
+// A store that fails to save any certificate
+type failingCertStore struct {
+	*MemoryCertStore
+}
+
+func (failingCertStore) Store(string, tls.Certificate) error {
+	return errors.New("store unavailable")
+}
+
+func TestNewAuditedCertStoreFailure(t *testing.T) {
+	var log bytes.Buffer
+	store := NewAuditedCertStore(failingCertStore{NewMemoryCertStore()}, &log)
+
+	cert, err := NewCert(WithEd25519())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if err := store.Store("localhost", cert); err == nil {
+		t.Error("Expected the store error to be returned")
+	}
+
+	if log.Len() != 0 {
+		t.Errorf("Expected no audit entry for a failed store, got %q\n", log.String())
+	}
+}
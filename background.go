@@ -0,0 +1,48 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import "log/slog"
+
+// BackgroundOption configures the functions that keep a goroutine running in
+// the background, such as AutoRenew and NewReloadingCertificate
+type BackgroundOption func(*backgroundConfig)
+
+// The resolved configuration of a background goroutine
+type backgroundConfig struct {
+	logger *slog.Logger
+}
+
+// WithLogger logs the background activity: periodic checks at Debug level,
+// renewals and reloads at Info, certificates nearing expiry at Warn, and
+// failures at Error. Nothing is logged by default.
+func WithLogger(logger *slog.Logger) BackgroundOption {
+	return func(c *backgroundConfig) {
+		c.logger = logger
+	}
+}
+
+// Resolve the options, discarding the logs unless a logger is set
+func newBackgroundConfig(opts []BackgroundOption) *backgroundConfig {
+	cfg := &backgroundConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	if cfg.logger == nil {
+		cfg.logger = slog.New(slog.DiscardHandler)
+	}
+
+	return cfg
+}
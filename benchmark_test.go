@@ -0,0 +1,51 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto/elliptic"
+	"testing"
+)
+
+// Compare the key algorithms with go test -bench=. -benchtime=10s. Each
+// iteration runs the full NewCert path: key generation, template creation,
+// signing and tls.X509KeyPair.
+
+func BenchmarkNewCertRSA2048(b *testing.B) {
+	benchmarkNewCert(b, WithRSAKeyBits(2048))
+}
+
+func BenchmarkNewCertRSA4096(b *testing.B) {
+	benchmarkNewCert(b, WithRSAKeyBits(4096))
+}
+
+func BenchmarkNewCertECDSAP256(b *testing.B) {
+	benchmarkNewCert(b, WithECDSACurve(elliptic.P256()))
+}
+
+func BenchmarkNewCertECDSAP384(b *testing.B) {
+	benchmarkNewCert(b, WithECDSACurve(elliptic.P384()))
+}
+
+func BenchmarkNewCertEd25519(b *testing.B) {
+	benchmarkNewCert(b, WithEd25519())
+}
+
+func benchmarkNewCert(b *testing.B, opts ...Option) {
+	for i := 0; i < b.N; i++ {
+		if _, err := NewCert(opts...); err != nil {
+			b.Fatalf("Unexpected error: %v\n", err)
+		}
+	}
+}
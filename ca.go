@@ -0,0 +1,181 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+)
+
+const (
+	caCommonName           = "PrivateTLS Root CA"
+	intermediateCommonName = "PrivateTLS Intermediate CA"
+)
+
+// NewCAAndLeafCert Generates a root CA certificate and a leaf certificate
+// signed by it. Unlike the certificate generated by NewCert, the leaf is not
+// a CA and cannot sign other certificates. The options apply to the leaf,
+// while the CA shares its key type and validity period but carries no SANs.
+// Name constraints, e.g. WithPermittedDNSDomains, only apply to the CA.
+// Install the CA in an x509.CertPool to verify the leaf.
+func NewCAAndLeafCert(opts ...Option) (caCert, leafCert tls.Certificate, err error) {
+	cfg := newCertConfig(opts)
+
+	caCert, err = issueCert(caConfig(cfg, caCommonName), setCAAttributes, nil, nil)
+	if err != nil {
+		return
+	}
+
+	caX509, caKey, err := parseCertAndSigner(caCert)
+	if err != nil {
+		return
+	}
+
+	leafCert, err = issueCert(cfg, setLeafAttributes, caX509, caKey)
+
+	return
+}
+
+// NewIntermediateCA Generates an intermediate CA certificate signed by the
+// supplied root, or by another intermediate. The intermediate can sign leaf
+// certificates, but not further intermediates. Unless set by the options,
+// the certificate carries no SANs. Name constraints set by the options, e.g.
+// WithPermittedDNSDomains, restrict the names the intermediate can sign.
+func NewIntermediateCA(rootCert *x509.Certificate, rootKey crypto.Signer, opts ...Option) (tls.Certificate, error) {
+	if rootCert == nil || rootKey == nil {
+		return tls.Certificate{}, errNoParent
+	}
+
+	cfg := applyOptions(caConfig(defaultCertConfig(), intermediateCommonName), opts)
+
+	return issueCert(cfg, setIntermediateAttributes, rootCert, rootKey)
+}
+
+// NewSignedLeafCert Generates a leaf certificate, as configured by the options,
+// signed by the supplied CA. If the CA is an intermediate, it is included in
+// the returned certificate chain.
+func NewSignedLeafCert(parentCert *x509.Certificate, parentKey crypto.Signer, opts ...Option) (tls.Certificate, error) {
+	if parentCert == nil || parentKey == nil {
+		return tls.Certificate{}, errNoParent
+	}
+
+	return issueCert(newCertConfig(opts), setLeafAttributes, parentCert, parentKey)
+}
+
+var (
+	errNotASigner = errors.New("privatetls: private key cannot sign certificates")
+	errNoParent   = errors.New("privatetls: parent certificate and key must not be nil")
+)
+
+// Generate a key and a certificate, see issueCertPEM. Unless the parent is a
+// root, it is appended to the certificate chain.
+func issueCert(cfg *certConfig, attributes func(*x509.Certificate, crypto.PublicKey),
+	parent *x509.Certificate, parentKey crypto.Signer) (tls.Certificate, error) {
+	certPEM, keyPEM, err := issueCertPEM(cfg, attributes, parent, parentKey)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	cert, err := x509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	appendParent(&cert, parent)
+
+	return cert, nil
+}
+
+// Append the issuer to the certificate chain, unless it is a self-signed root
+// that clients are expected to have already
+func appendParent(cert *tls.Certificate, parent *x509.Certificate) {
+	if parent != nil && !bytes.Equal(parent.RawSubject, parent.RawIssuer) {
+		cert.Certificate = append(cert.Certificate, parent.Raw)
+	}
+}
+
+// Derive the configuration of a CA from another configuration, e.g. that of
+// the leaf it will sign. CA certificates carry no SANs and no SCTs, and keep
+// the CA key usages.
+func caConfig(base *certConfig, commonName string) *certConfig {
+	cfg := withoutSANs(base)
+	cfg.commonName = commonName
+	cfg.keyUsage = 0
+	cfg.extKeyUsage = nil
+	cfg.scts = nil
+	cfg.ctLogs = nil
+
+	return cfg
+}
+
+// Copy the configuration, dropping the SANs
+func withoutSANs(base *certConfig) *certConfig {
+	cfg := *base
+	cfg.ipAddresses = nil
+	cfg.dnsNames = nil
+	cfg.uris = nil
+	cfg.emailAddresses = nil
+
+	return &cfg
+}
+
+// Extract the parsed certificate and the signing key of a TLS certificate
+func parseCertAndSigner(cert tls.Certificate) (*x509.Certificate, crypto.Signer, error) {
+	x509Cert, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	signer, ok := cert.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, nil, errNotASigner
+	}
+
+	return x509Cert, signer, nil
+}
+
+// Mark the template as a CA certificate that only signs other certificates
+func setCAAttributes(t *x509.Certificate, _ crypto.PublicKey) {
+	t.IsCA = true
+	t.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature
+}
+
+// Mark the template as a CA certificate that can only sign leaf certificates
+func setIntermediateAttributes(t *x509.Certificate, pub crypto.PublicKey) {
+	setCAAttributes(t, pub)
+	t.MaxPathLen = 0
+	t.MaxPathLenZero = true
+}
+
+// Mark the template as an end-entity certificate for TLS clients only
+func setClientAttributes(t *x509.Certificate, pub crypto.PublicKey) {
+	setLeafAttributes(t, pub)
+	t.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+}
+
+// Mark the template as an end-entity certificate for TLS servers and clients
+func setLeafAttributes(t *x509.Certificate, pub crypto.PublicKey) {
+	t.IsCA = false
+	t.KeyUsage = x509.KeyUsageDigitalSignature
+	t.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+
+	// RSA key exchange in TLS 1.2 encrypts the pre-master secret with the key
+	if _, ok := pub.(*rsa.PublicKey); ok {
+		t.KeyUsage |= x509.KeyUsageKeyEncipherment
+	}
+}
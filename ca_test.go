@@ -0,0 +1,152 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"testing"
+)
+
+func TestNewCAAndLeafCert(t *testing.T) {
+	caCert, leafCert, err := NewCAAndLeafCert(WithEd25519(), WithDNSNames("myservice.internal"))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	ca := mustParseCert(t, caCert)
+	leaf := mustParseCert(t, leafCert)
+
+	if !ca.IsCA || ca.KeyUsage&x509.KeyUsageCertSign == 0 {
+		t.Errorf("Expected a CA certificate, got IsCA=%v, KeyUsage=%v\n", ca.IsCA, ca.KeyUsage)
+	}
+
+	if leaf.IsCA || leaf.KeyUsage&x509.KeyUsageCertSign != 0 {
+		t.Errorf("Expected a leaf certificate, got IsCA=%v, KeyUsage=%v\n", leaf.IsCA, leaf.KeyUsage)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	if _, err := leaf.Verify(x509.VerifyOptions{DNSName: "myservice.internal", Roots: roots}); err != nil {
+		t.Errorf("Unexpected error: %v\n", err)
+	}
+}
+
+func TestCertificateHierarchy(t *testing.T) {
+	rootCert, _, err := NewCAAndLeafCert(WithEd25519())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	root, rootKey, err := parseCertAndSigner(rootCert)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	intermediateCert, err := NewIntermediateCA(root, rootKey, WithEd25519())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	intermediate, intermediateKey, err := parseCertAndSigner(intermediateCert)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if !intermediate.IsCA || intermediate.MaxPathLen != 0 || !intermediate.MaxPathLenZero {
+		t.Errorf("Unexpected intermediate attributes: IsCA=%v, MaxPathLen=%d\n", intermediate.IsCA, intermediate.MaxPathLen)
+	}
+
+	leafCert, err := NewSignedLeafCert(intermediate, intermediateKey, WithEd25519())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if len(leafCert.Certificate) != 2 {
+		t.Fatalf("Expected the intermediate in the chain, got %d certificates\n", len(leafCert.Certificate))
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+
+	intermediates := x509.NewCertPool()
+	intermediates.AddCert(mustParseCert(t, tls.Certificate{Certificate: leafCert.Certificate[1:]}))
+
+	opts := x509.VerifyOptions{DNSName: "localhost", Roots: roots, Intermediates: intermediates}
+	if _, err := mustParseCert(t, leafCert).Verify(opts); err != nil {
+		t.Errorf("Unexpected error: %v\n", err)
+	}
+}
+
+func TestNewSignedLeafCertNoParent(t *testing.T) {
+	if _, err := NewSignedLeafCert(nil, nil); err == nil {
+		t.Error("Expected an error for a nil parent")
+	}
+}
+
+// Parse the leaf of a TLS certificate
+func mustParseCert(t *testing.T, cert tls.Certificate) *x509.Certificate {
+	t.Helper()
+
+	x509Cert, err := x509.ParseCertificate(cert.Certificate[0])
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	return x509Cert
+}
+
+func TestNameConstraints(t *testing.T) {
+	caCert, leafCert, err := NewCAAndLeafCert(WithEd25519(), WithPermittedDNSDomains("internal"), WithDNSNames("svc.internal"))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	ca, caKey, err := parseCertAndSigner(caCert)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	leaf := mustParseCert(t, leafCert)
+	if len(ca.PermittedDNSDomains) != 1 || len(leaf.PermittedDNSDomains) != 0 {
+		t.Errorf("Expected name constraints on the CA only\n")
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	if _, err := leaf.Verify(x509.VerifyOptions{DNSName: "svc.internal", Roots: roots}); err != nil {
+		t.Errorf("Unexpected error: %v\n", err)
+	}
+
+	outside, err := NewSignedLeafCert(ca, caKey, WithEd25519(), WithDNSNames("example.com"))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if _, err := mustParseCert(t, outside).Verify(x509.VerifyOptions{DNSName: "example.com", Roots: roots}); err == nil {
+		t.Error("Expected an error for a name outside the permitted domains")
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// ValidateChain verifies that the leaf, the first certificate of chain[0],
+// chains to one of the roots through the other certificates, as a TLS client
+// would. Intermediates may follow the leaf in chain[0] or come in further
+// elements. If roots is nil the system roots are used. The error names the
+// offending certificate when one is expired or not yet valid, when the chain
+// is not valid for server authentication, or when it does not reach a
+// trusted root.
+func ValidateChain(chain []tls.Certificate, roots *x509.CertPool) error {
+	var certs []*x509.Certificate
+
+	for _, c := range chain {
+		for _, der := range c.Certificate {
+			cert, err := x509.ParseCertificate(der)
+			if err != nil {
+				return fmt.Errorf("privatetls: certificate %d: %w", len(certs), err)
+			}
+			certs = append(certs, cert)
+		}
+	}
+
+	if len(certs) == 0 {
+		return errEmptyChain
+	}
+
+	now := time.Now()
+	intermediates := x509.NewCertPool()
+
+	for i, cert := range certs {
+		switch {
+		case now.After(cert.NotAfter):
+			return fmt.Errorf("privatetls: certificate %d (%s) expired at %v", i, cert.Subject, cert.NotAfter)
+		case now.Before(cert.NotBefore):
+			return fmt.Errorf("privatetls: certificate %d (%s) is not valid before %v", i, cert.Subject, cert.NotBefore)
+		}
+
+		if i > 0 {
+			intermediates.AddCert(cert)
+		}
+	}
+
+	_, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+
+	var invalid x509.CertificateInvalidError
+	var unknown x509.UnknownAuthorityError
+
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &invalid) && invalid.Reason == x509.IncompatibleUsage:
+		return fmt.Errorf("privatetls: key usage of %s does not allow server authentication: %w", invalid.Cert.Subject, err)
+	case errors.As(err, &unknown):
+		return fmt.Errorf("privatetls: chain of %s does not reach a trusted root: %w", certs[0].Subject, err)
+	default:
+		return fmt.Errorf("privatetls: invalid chain: %w", err)
+	}
+}
+
+// CheckCertForHostname verifies that the certificate is valid for the host
+// name or IP address, like cert.VerifyHostname. When it is not, the error
+// explains why, listing the SANs of the certificate, e.g. "certificate has
+// SANs [localhost, 127.0.0.1] but requested hostname is myservice.example.com".
+func CheckCertForHostname(cert *x509.Certificate, hostname string) error {
+	if cert == nil {
+		return errors.New("privatetls: certificate must not be nil")
+	}
+
+	if cert.VerifyHostname(hostname) == nil {
+		return nil
+	}
+
+	var sans []string
+	sans = append(sans, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+
+	if len(sans) == 0 {
+		if cert.Subject.CommonName != "" {
+			return fmt.Errorf("privatetls: certificate has no SANs, and its common name %q is not used for hostname verification, requested hostname is %s",
+				cert.Subject.CommonName, hostname)
+		}
+		return fmt.Errorf("privatetls: certificate has no SANs, requested hostname is %s", hostname)
+	}
+
+	msg := fmt.Sprintf("privatetls: certificate has SANs [%s] but requested hostname is %s", strings.Join(sans, ", "), hostname)
+
+	host := strings.TrimSuffix(strings.TrimPrefix(hostname, "["), "]")
+	if ip := net.ParseIP(host); ip != nil {
+		if len(cert.IPAddresses) == 0 {
+			msg += ", and IP addresses only match IP SANs"
+		}
+		return errors.New(msg)
+	}
+
+	// Wildcards match a single label, so *.example.com does not cover a.b.example.com
+	name := strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, san := range cert.DNSNames {
+		suffix, ok := strings.CutPrefix(strings.ToLower(san), "*")
+		if ok && strings.HasSuffix(name, suffix) && strings.Contains(strings.TrimSuffix(name, suffix), ".") {
+			msg += fmt.Sprintf(", and the wildcard %s only matches a single label", san)
+			break
+		}
+	}
+
+	return errors.New(msg)
+}
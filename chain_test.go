@@ -0,0 +1,125 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestValidateChain(t *testing.T) {
+	rootCert, _, err := NewCAAndLeafCert(WithEd25519())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	root, rootKey, err := parseCertAndSigner(rootCert)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	intermediateCert, err := NewIntermediateCA(root, rootKey, WithEd25519())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	intermediate, intermediateKey, err := parseCertAndSigner(intermediateCert)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	leafCert, err := NewSignedLeafCert(intermediate, intermediateKey, WithEd25519())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+
+	if err := ValidateChain([]tls.Certificate{leafCert}, roots); err != nil {
+		t.Errorf("Unexpected error: %v\n", err)
+	}
+
+	// The leaf alone does not reach the root
+	leafOnly := tls.Certificate{Certificate: leafCert.Certificate[:1]}
+	if err := ValidateChain([]tls.Certificate{leafOnly}, roots); err == nil || !strings.Contains(err.Error(), "trusted root") {
+		t.Errorf("Expected an untrusted chain error, got %v\n", err)
+	}
+
+	if err := ValidateChain([]tls.Certificate{leafOnly, intermediateCert}, roots); err != nil {
+		t.Errorf("Unexpected error: %v\n", err)
+	}
+
+	clientCert, err := NewSignedLeafCert(intermediate, intermediateKey, WithEd25519(), WithExtKeyUsage(x509.ExtKeyUsageClientAuth))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if err := ValidateChain([]tls.Certificate{clientCert}, roots); err == nil || !strings.Contains(err.Error(), "key usage") {
+		t.Errorf("Expected a key usage error, got %v\n", err)
+	}
+
+	if err := ValidateChain([]tls.Certificate{expiredCert(t)}, roots); err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("Expected an expiry error, got %v\n", err)
+	}
+}
+
+func TestCheckCertForHostname(t *testing.T) {
+	cert, err := NewCert(WithEd25519(), WithDNSNames("localhost", "*.example.com"), WithIPAddresses(net.ParseIP("127.0.0.1")))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	for _, host := range []string{"localhost", "a.example.com", "127.0.0.1"} {
+		if err := CheckCertForHostname(cert.Leaf, host); err != nil {
+			t.Errorf("Unexpected error for %s: %v\n", host, err)
+		}
+	}
+
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"myservice.internal", "certificate has SANs [localhost, *.example.com, 127.0.0.1] but requested hostname is myservice.internal"},
+		{"a.b.example.com", "wildcard *.example.com only matches a single label"},
+		{"10.0.0.1", "requested hostname is 10.0.0.1"},
+	}
+
+	for _, test := range tests {
+		err := CheckCertForHostname(cert.Leaf, test.host)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("Expected an error containing %q for %s, got: %v\n", test.want, test.host, err)
+		}
+	}
+
+	noSANs, err := NewCert(WithEd25519(), WithDNSNames(), WithIPAddresses(), WithCommonName("legacy.internal"))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if err := CheckCertForHostname(noSANs.Leaf, "legacy.internal"); err == nil || !strings.Contains(err.Error(), "common name") {
+		t.Errorf("Expected an error about the common name, got: %v\n", err)
+	}
+}
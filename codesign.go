@@ -0,0 +1,103 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// NewCodeSigningCert Generates a self-signed code signing certificate for the
+// organization, e.g. to sign build artifacts in a private CI pipeline. The
+// certificate is valid for code signing only, for digital signatures and
+// non-repudiation, is not a CA, and carries no SANs. Sign artifacts with
+// SignArtifact and check them with VerifyCodeSignature.
+func NewCodeSigningCert(organization, cn string, opts ...Option) (tls.Certificate, error) {
+	cfg := withoutSANs(defaultCertConfig())
+	cfg.organization = []string{organization}
+	cfg.commonName = cn
+
+	return issueCert(applyOptions(cfg, opts), setCodeSigningAttributes, nil, nil)
+}
+
+// SignArtifact signs the artifact with the key of the certificate, with the
+// signature algorithm the package uses for the key type, e.g. SHA-256 with
+// PKCS #1 v1.5 for RSA keys. The signature is returned raw, without a
+// container format such as CMS.
+func SignArtifact(artifact []byte, cert tls.Certificate) ([]byte, error) {
+	signer, ok := cert.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, errNotASigner
+	}
+
+	hash := signatureHash(signatureAlgorithm(signer.Public()))
+	digest := artifact
+	if hash != 0 {
+		h := hash.New()
+		h.Write(artifact)
+		digest = h.Sum(nil)
+	}
+
+	return signer.Sign(rand.Reader, digest, hash)
+}
+
+// VerifyCodeSignature checks that signature is a signature of the artifact by
+// the key of the certificate, made by SignArtifact, and that the certificate
+// is valid for code signing at the current time. It does not check who
+// issued the certificate; verify its chain with x509.Certificate.Verify.
+func VerifyCodeSignature(artifact []byte, signature []byte, cert *x509.Certificate) error {
+	if cert == nil {
+		return errors.New("privatetls: certificate must not be nil")
+	}
+
+	if !hasExtKeyUsage(cert, x509.ExtKeyUsageCodeSigning) {
+		return errors.New("privatetls: certificate is not valid for code signing")
+	}
+
+	if now := time.Now(); now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return fmt.Errorf("privatetls: certificate is only valid from %v to %v", cert.NotBefore, cert.NotAfter)
+	}
+
+	if err := cert.CheckSignature(signatureAlgorithm(cert.PublicKey), artifact, signature); err != nil {
+		return fmt.Errorf("privatetls: invalid code signature: %w", err)
+	}
+
+	return nil
+}
+
+// Mark the template as an end-entity certificate for code signing
+func setCodeSigningAttributes(t *x509.Certificate, _ crypto.PublicKey) {
+	t.IsCA = false
+	t.KeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment
+	t.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning}
+}
+
+// The hash signed by the signature algorithm, or zero if it signs the message itself
+func signatureHash(algo x509.SignatureAlgorithm) crypto.Hash {
+	switch algo {
+	case x509.ECDSAWithSHA512:
+		return crypto.SHA512
+	case x509.ECDSAWithSHA384:
+		return crypto.SHA384
+	case x509.PureEd25519:
+		return 0
+	default:
+		return crypto.SHA256
+	}
+}
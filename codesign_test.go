@@ -0,0 +1,85 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto/elliptic"
+	"crypto/x509"
+	"testing"
+)
+
+func TestNewCodeSigningCert(t *testing.T) {
+	cert, err := NewCodeSigningCert("Acme Corp", "Acme Release Signing", WithEd25519())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	leaf := cert.Leaf
+
+	if leaf.KeyUsage != x509.KeyUsageDigitalSignature|x509.KeyUsageContentCommitment ||
+		len(leaf.ExtKeyUsage) != 1 || leaf.ExtKeyUsage[0] != x509.ExtKeyUsageCodeSigning {
+		t.Errorf("Unexpected key usages: %v %v\n", leaf.KeyUsage, leaf.ExtKeyUsage)
+	}
+
+	if leaf.IsCA || !leaf.BasicConstraintsValid || len(leaf.DNSNames) != 0 || len(leaf.IPAddresses) != 0 {
+		t.Errorf("Unexpected CA flag or SANs: %v %v %v\n", leaf.IsCA, leaf.DNSNames, leaf.IPAddresses)
+	}
+
+	if leaf.Subject.Organization[0] != "Acme Corp" || leaf.Subject.CommonName != "Acme Release Signing" {
+		t.Errorf("Unexpected subject: %v\n", leaf.Subject)
+	}
+}
+
+func TestVerifyCodeSignature(t *testing.T) {
+	artifact := []byte("release binary")
+
+	for _, opt := range []Option{WithEd25519(), WithECDSACurve(elliptic.P384()), WithRSAKeyBits(1024)} {
+		cert, err := NewCodeSigningCert("Acme Corp", "Acme Release Signing", opt)
+
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+
+		signature, err := SignArtifact(artifact, cert)
+
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+
+		if err := VerifyCodeSignature(artifact, signature, cert.Leaf); err != nil {
+			t.Errorf("Unexpected error for %v: %v\n", cert.Leaf.PublicKeyAlgorithm, err)
+		}
+
+		if err := VerifyCodeSignature([]byte("tampered binary"), signature, cert.Leaf); err == nil {
+			t.Errorf("Expected an error for a tampered artifact with %v\n", cert.Leaf.PublicKeyAlgorithm)
+		}
+	}
+
+	server, err := NewCert(WithEd25519())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	signature, err := SignArtifact(artifact, server)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if err := VerifyCodeSignature(artifact, signature, server.Leaf); err == nil {
+		t.Error("Expected an error for a certificate not valid for code signing")
+	}
+}
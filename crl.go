@@ -0,0 +1,55 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"time"
+)
+
+// NewCRL Generates a PEM-encoded "X509 CRL" signed by the CA, listing the
+// revoked certificates and valid until nextUpdate. The CA certificate must
+// allow CRL signing, as the CAs created by NewCAAndLeafCert and
+// NewIntermediateCA do. The CRL number is derived from the current time, so
+// that successive CRLs have increasing numbers.
+func NewCRL(caKey crypto.Signer, caCert *x509.Certificate, revokedCerts []pkix.RevokedCertificate, nextUpdate time.Time) ([]byte, error) {
+	now := time.Now()
+
+	entries := make([]x509.RevocationListEntry, 0, len(revokedCerts))
+	for _, rc := range revokedCerts {
+		entries = append(entries, x509.RevocationListEntry{
+			SerialNumber:   rc.SerialNumber,
+			RevocationTime: rc.RevocationTime,
+			Extensions:     rc.Extensions,
+		})
+	}
+
+	t := x509.RevocationList{
+		Number:                    big.NewInt(now.UnixNano()),
+		ThisUpdate:                now,
+		NextUpdate:                nextUpdate,
+		RevokedCertificateEntries: entries,
+	}
+
+	der, err := x509.CreateRevocationList(rand.Reader, &t, caCert, caKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return DERToPEM(der, "X509 CRL"), nil
+}
@@ -0,0 +1,64 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"testing"
+	"time"
+)
+
+func TestNewCRL(t *testing.T) {
+	caCert, leafCert, err := NewCAAndLeafCert(WithEd25519(), WithCRLDistributionPoints("http://ca.internal/crl.pem"))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	ca := mustParseCert(t, caCert)
+	leaf := mustParseCert(t, leafCert)
+
+	if len(leaf.CRLDistributionPoints) != 1 || leaf.CRLDistributionPoints[0] != "http://ca.internal/crl.pem" {
+		t.Errorf("Unexpected CRL distribution points: %v\n", leaf.CRLDistributionPoints)
+	}
+
+	revoked := []pkix.RevokedCertificate{{SerialNumber: leaf.SerialNumber, RevocationTime: time.Now()}}
+	crlPEM, err := NewCRL(caCert.PrivateKey.(crypto.Signer), ca, revoked, time.Now().Add(time.Hour))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	b, _ := pem.Decode(crlPEM)
+	if b == nil || b.Type != "X509 CRL" {
+		t.Fatalf("Expected an X509 CRL PEM block\n")
+	}
+
+	crl, err := x509.ParseRevocationList(b.Bytes)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if err := crl.CheckSignatureFrom(ca); err != nil {
+		t.Errorf("Unexpected error: %v\n", err)
+	}
+
+	if len(crl.RevokedCertificateEntries) != 1 || crl.RevokedCertificateEntries[0].SerialNumber.Cmp(leaf.SerialNumber) != 0 {
+		t.Errorf("Expected the leaf certificate to be revoked\n")
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// NewCSR creates a PEM-encoded certificate signing request for the key. The
+// options set the subject and the SANs of the request; unless set, the request
+// carries no SANs. Key type and validity options do not apply.
+func NewCSR(key crypto.Signer, opts ...Option) ([]byte, error) {
+	if key == nil {
+		return nil, errors.New("privatetls: key must not be nil")
+	}
+
+	cfg := applyOptions(withoutSANs(defaultCertConfig()), opts)
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	template := x509.CertificateRequest{
+		Subject:        cfg.subject(),
+		DNSNames:       cfg.dnsNames,
+		IPAddresses:    cfg.ipAddresses,
+		EmailAddresses: cfg.emailAddresses,
+		URIs:           cfg.uris,
+	}
+
+	der, err := x509.CreateCertificateRequest(rand.Reader, &template, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return DERToPEM(der, "CERTIFICATE REQUEST"), nil
+}
+
+// SignCSR issues a leaf certificate for a PEM-encoded certificate signing
+// request, signed by the CA. The subject and SANs are taken from the request,
+// while the options control the rest of the certificate, such as its validity
+// and key usages, e.g. ClientAuthProfile(). The requester keeps the private
+// key, so the PrivateKey of the returned certificate is nil.
+func SignCSR(csrPEM []byte, caCert *x509.Certificate, caKey crypto.Signer, opts ...Option) (tls.Certificate, error) {
+	if caCert == nil || caKey == nil {
+		return tls.Certificate{}, errNoParent
+	}
+
+	b, _ := pem.Decode(csrPEM)
+	if b == nil || b.Type != "CERTIFICATE REQUEST" {
+		return tls.Certificate{}, errors.New("privatetls: no CERTIFICATE REQUEST PEM block found")
+	}
+
+	csr, err := x509.ParseCertificateRequest(b.Bytes)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	if err := csr.CheckSignature(); err != nil {
+		return tls.Certificate{}, fmt.Errorf("privatetls: invalid certificate request signature: %w", err)
+	}
+
+	cfg := newCertConfig(opts)
+	if err := cfg.validate(); err != nil {
+		return tls.Certificate{}, err
+	}
+
+	// The subject and SANs are those requested, the rest is configured
+	attributes := func(t *x509.Certificate, pub crypto.PublicKey) {
+		t.Subject = csr.Subject
+		t.DNSNames = csr.DNSNames
+		t.IPAddresses = csr.IPAddresses
+		t.EmailAddresses = csr.EmailAddresses
+		t.URIs = csr.URIs
+		setLeafAttributes(t, pub)
+	}
+
+	certPEM, err := issueCertPEMForKey(cfg, attributes, csr.PublicKey, caCert, caKey)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	certBlock, _ := pem.Decode(certPEM)
+
+	leaf, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	cert := tls.Certificate{Certificate: [][]byte{leaf.Raw}, Leaf: leaf}
+	appendParent(&cert, caCert)
+
+	return cert, nil
+}
@@ -0,0 +1,118 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+)
+
+func TestCSR(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	csrPEM, err := NewCSR(key, WithCommonName("device-42"), WithDNSNames("device-42.internal"))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if b, _ := pem.Decode(csrPEM); b == nil || b.Type != "CERTIFICATE REQUEST" {
+		t.Fatalf("Expected a CERTIFICATE REQUEST PEM block, got %q\n", csrPEM)
+	}
+
+	caCert, _, err := NewCAAndLeafCert(WithEd25519())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	ca, caKey, err := parseCertAndSigner(caCert)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	cert, err := SignCSR(csrPEM, ca, caKey)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if cert.Leaf.Subject.CommonName != "device-42" || len(cert.Leaf.DNSNames) != 1 {
+		t.Errorf("Unexpected subject or SANs: %v %v\n", cert.Leaf.Subject, cert.Leaf.DNSNames)
+	}
+
+	if !cert.Leaf.PublicKey.(*ecdsa.PublicKey).Equal(&key.PublicKey) {
+		t.Error("Certificate does not carry the requester's public key")
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	if _, err := cert.Leaf.Verify(x509.VerifyOptions{DNSName: "device-42.internal", Roots: roots}); err != nil {
+		t.Errorf("Unexpected error: %v\n", err)
+	}
+
+	if _, err := SignCSR([]byte("not a CSR"), ca, caKey); err == nil {
+		t.Error("Expected an error for an invalid CSR")
+	}
+}
+
+func TestSignCSRKeyUsage(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	csrPEM, err := NewCSR(key, WithCommonName("client"))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	caCert, _, err := NewCAAndLeafCert(WithEd25519())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	ca, caKey, err := parseCertAndSigner(caCert)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	cert, err := SignCSR(csrPEM, ca, caKey, ClientAuthProfile()...)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if eku := cert.Leaf.ExtKeyUsage; len(eku) != 1 || eku[0] != x509.ExtKeyUsageClientAuth {
+		t.Errorf("Expected client authentication only, got %v\n", eku)
+	}
+
+	if cert.Leaf.KeyUsage != x509.KeyUsageDigitalSignature {
+		t.Errorf("Unexpected key usage %v\n", cert.Leaf.KeyUsage)
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	ctRequestTimeout  = 30 * time.Second
+	ctMaxResponseSize = 1 << 16
+	ctMaxListLength   = 1<<16 - 1
+	ctLogIDLength     = 32
+	ctAddChainPath    = "/ct/v1/add-chain"
+	ctAddPreChainPath = "/ct/v1/add-pre-chain"
+)
+
+var (
+	oidSCTList    = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+	oidCTPoison   = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 3}
+	errSCTTooLong = errors.New("privatetls: signed certificate timestamps exceed the maximum list length")
+)
+
+// WithSCT embeds a serialized Signed Certificate Timestamp, as returned by
+// FetchSCT, in the certificate. Use the option once per log. An SCT only
+// verifies for the certificate whose precertificate was logged, which has the
+// same serial number, key and contents, so SCTs are best obtained while
+// issuing the certificate with WithCTLogs.
+func WithSCT(sctBytes []byte) Option {
+	return func(c *certConfig) {
+		c.scts = append(c.scts, sctBytes)
+	}
+}
+
+// WithCTLogs submits a precertificate to each of the RFC 6962 certificate
+// transparency logs, and embeds the returned SCTs in the certificate issued
+// from the same template and key, e.g. by NewSignedLeafCert or SignCSR. The
+// precertificate is signed by the issuing CA and carries the CT poison
+// extension, so clients don't accept it in place of the certificate. The
+// logs must accept the issuing CA, see FetchSCT. The option does not apply to
+// self-signed certificates, which have no issuer to submit.
+func WithCTLogs(logURLs ...string) Option {
+	return func(c *certConfig) {
+		c.ctLogs = append(c.ctLogs, logURLs...)
+	}
+}
+
+// FetchSCT submits the DER encoded certificate to the RFC 6962 certificate
+// transparency log at logURL and returns the serialized SCT, ready for
+// WithSCT. Logs only accept certificates they can chain to a root they
+// accept, so certDER must be followed by the DER encoded issuers, starting
+// with the one that signed the certificate, as x509.ParseCertificates reads
+// them. A precertificate, i.e. one carrying the CT poison extension, is
+// submitted with add-pre-chain, otherwise add-chain is used.
+//
+// Public logs only accept certificates chaining to publicly trusted roots, so
+// self-signed and private CA certificates will be rejected by them. For
+// development use a test log that accepts your root, e.g. a Sunlight
+// instance.
+func FetchSCT(certDER []byte, logURL string) ([]byte, error) {
+	chain, err := x509.ParseCertificates(certDER)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(chain) < 2 {
+		return nil, errors.New("privatetls: CT log submission requires the issuer chain after the certificate")
+	}
+
+	cert := chain[0]
+
+	path := ctAddChainPath
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oidCTPoison) {
+			path = ctAddPreChainPath
+		}
+	}
+
+	submitted := make([][]byte, len(chain))
+	for i, c := range chain {
+		submitted[i] = c.Raw
+	}
+
+	body, err := json.Marshal(struct {
+		Chain [][]byte `json:"chain"`
+	}{submitted})
+	if err != nil {
+		return nil, err
+	}
+
+	client := http.Client{Timeout: ctRequestTimeout}
+	resp, err := client.Post(strings.TrimSuffix(logURL, "/")+path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("privatetls: CT log returned %s", resp.Status)
+	}
+
+	// The binary fields are base64 encoded, which encoding/json decodes into []byte
+	var sct struct {
+		Version    uint8  `json:"sct_version"`
+		ID         []byte `json:"id"`
+		Timestamp  uint64 `json:"timestamp"`
+		Extensions []byte `json:"extensions"`
+		Signature  []byte `json:"signature"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, ctMaxResponseSize)).Decode(&sct); err != nil {
+		return nil, fmt.Errorf("privatetls: malformed CT log response: %w", err)
+	}
+
+	if len(sct.ID) != ctLogIDLength || len(sct.Signature) == 0 {
+		return nil, errors.New("privatetls: malformed CT log response")
+	}
+
+	// Serialize the SCT as defined by RFC 6962, section 3.2. The signature is
+	// already a serialized DigitallySigned structure.
+	b := make([]byte, 0, 1+ctLogIDLength+8+2+len(sct.Extensions)+len(sct.Signature))
+	b = append(b, sct.Version)
+	b = append(b, sct.ID...)
+	b = binary.BigEndian.AppendUint64(b, sct.Timestamp)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(sct.Extensions)))
+	b = append(b, sct.Extensions...)
+	b = append(b, sct.Signature...)
+
+	return b, nil
+}
+
+// Log a precertificate of the template, signed by the issuer, and add the
+// returned SCTs to those of WithSCT in the template
+func embedSCTs(cfg *certConfig, t *x509.Certificate, pub crypto.PublicKey, issuer *x509.Certificate, issuerKey crypto.Signer) error {
+	if issuer == nil {
+		return errors.New("privatetls: CT logging requires a certificate issued by a CA")
+	}
+
+	// The precertificate differs from the certificate only by the poison
+	// extension in place of the SCTs
+	var exts []pkix.Extension
+	for _, ext := range t.ExtraExtensions {
+		if !ext.Id.Equal(oidSCTList) {
+			exts = append(exts, ext)
+		}
+	}
+
+	pre := *t
+	pre.ExtraExtensions = append(append([]pkix.Extension(nil), exts...), pkix.Extension{Id: oidCTPoison, Critical: true, Value: asn1.NullBytes})
+
+	preDER, err := x509.CreateCertificate(cfg.randReader(), &pre, issuer, pub, issuerKey)
+	if err != nil {
+		return err
+	}
+
+	chain := append(preDER, issuer.Raw...)
+	scts := append([][]byte(nil), cfg.scts...)
+	for _, logURL := range cfg.ctLogs {
+		sct, err := FetchSCT(chain, logURL)
+		if err != nil {
+			return fmt.Errorf("privatetls: logging the precertificate to %s: %w", logURL, err)
+		}
+		scts = append(scts, sct)
+	}
+
+	ext, err := sctListExtension(scts)
+	if err != nil {
+		return err
+	}
+
+	t.ExtraExtensions = append(exts, ext)
+	return nil
+}
+
+// Build the SCT list extension: an OCTET STRING holding the TLS encoded list
+// of length-prefixed SCTs
+func sctListExtension(scts [][]byte) (pkix.Extension, error) {
+	var list []byte
+	for _, sct := range scts {
+		if len(sct) > ctMaxListLength {
+			return pkix.Extension{}, errSCTTooLong
+		}
+		list = binary.BigEndian.AppendUint16(list, uint16(len(sct)))
+		list = append(list, sct...)
+	}
+
+	if len(list) > ctMaxListLength {
+		return pkix.Extension{}, errSCTTooLong
+	}
+
+	value, err := asn1.Marshal(append(binary.BigEndian.AppendUint16(nil, uint16(len(list))), list...))
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+
+	return pkix.Extension{Id: oidSCTList, Value: value}, nil
+}
@@ -0,0 +1,157 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFetchSCT(t *testing.T) {
+	logID := bytes.Repeat([]byte{0xAB}, 32)
+	signature := []byte{4, 3, 0, 2, 0xDE, 0xAD}
+
+	var chainLength int
+	log := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ct/v1/add-chain" {
+			http.NotFound(w, r)
+			return
+		}
+
+		var req struct {
+			Chain [][]byte `json:"chain"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		chainLength = len(req.Chain)
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"sct_version": 0,
+			"id":          logID,
+			"timestamp":   1,
+			"extensions":  []byte{},
+			"signature":   signature,
+		})
+	}))
+	defer log.Close()
+
+	caCert, cert, err := NewCAAndLeafCert(WithEd25519())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	chain := append(append([]byte(nil), cert.Certificate[0]...), caCert.Certificate[0]...)
+	sct, err := FetchSCT(chain, log.URL)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if chainLength != 2 {
+		t.Errorf("Expected the leaf and its issuer to be submitted, got %d certificates\n", chainLength)
+	}
+
+	want := append(append([]byte{0}, logID...), 0, 0, 0, 0, 0, 0, 0, 1, 0, 0)
+	want = append(want, signature...)
+	if !bytes.Equal(sct, want) {
+		t.Errorf("Unexpected SCT: %x\n", sct)
+	}
+
+	cert, err = NewCert(WithEd25519(), WithSCT(sct))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	var list []byte
+	for _, ext := range cert.Leaf.Extensions {
+		if ext.Id.Equal(oidSCTList) {
+			if _, err := asn1.Unmarshal(ext.Value, &list); err != nil {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+		}
+	}
+
+	wantList := append([]byte{0, byte(len(sct) + 2), 0, byte(len(sct))}, sct...)
+	if !bytes.Equal(list, wantList) {
+		t.Errorf("Unexpected SCT list: %x\n", list)
+	}
+
+	if _, err := FetchSCT(chain, log.URL+"/missing"); err == nil {
+		t.Error("Expected an error for a failing log")
+	}
+
+	if _, err := FetchSCT(cert.Certificate[0], log.URL); err == nil {
+		t.Error("Expected an error without the issuer chain")
+	}
+}
+
+func TestWithCTLogs(t *testing.T) {
+	var precert *x509.Certificate
+	log := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ct/v1/add-pre-chain" {
+			http.NotFound(w, r)
+			return
+		}
+
+		var req struct {
+			Chain [][]byte `json:"chain"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		precert, _ = x509.ParseCertificate(req.Chain[0])
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"sct_version": 0,
+			"id":          bytes.Repeat([]byte{0xAB}, 32),
+			"timestamp":   1,
+			"signature":   []byte{4, 3, 0, 2, 0xDE, 0xAD},
+		})
+	}))
+	defer log.Close()
+
+	_, cert, err := NewCAAndLeafCert(WithEd25519(), WithCTLogs(log.URL))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if precert == nil {
+		t.Fatal("Expected a precertificate to be logged")
+	}
+
+	if precert.SerialNumber.Cmp(cert.Leaf.SerialNumber) != 0 || !bytes.Equal(precert.RawSubjectPublicKeyInfo, cert.Leaf.RawSubjectPublicKeyInfo) {
+		t.Error("Expected the precertificate to share the serial number and key of the certificate")
+	}
+
+	var hasSCTs bool
+	for _, ext := range cert.Leaf.Extensions {
+		if ext.Id.Equal(oidCTPoison) {
+			t.Error("Unexpected poison extension in the certificate")
+		}
+		hasSCTs = hasSCTs || ext.Id.Equal(oidSCTList)
+	}
+
+	if !hasSCTs {
+		t.Error("Expected the SCTs to be embedded")
+	}
+
+	if _, err := NewCert(WithEd25519(), WithCTLogs(log.URL)); err == nil {
+		t.Error("Expected an error for a self-signed certificate")
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"strings"
+)
+
+// CertToDER returns the DER encoding of the leaf certificate and of the
+// private key, as PKCS1 for RSA keys and as PKCS8 for other keys. DER is
+// the format expected by Java's keytool and by some hardware security modules.
+func CertToDER(cert tls.Certificate) (certDER, keyDER []byte, err error) {
+	if len(cert.Certificate) == 0 {
+		return nil, nil, errEmptyChain
+	}
+
+	if key, ok := cert.PrivateKey.(*rsa.PrivateKey); ok {
+		keyDER = x509.MarshalPKCS1PrivateKey(key)
+	} else if keyDER, err = x509.MarshalPKCS8PrivateKey(cert.PrivateKey); err != nil {
+		return nil, nil, err
+	}
+
+	return cert.Certificate[0], keyDER, nil
+}
+
+// DERToPEM PEM encodes DER data, e.g. returned by hardware that only speaks
+// DER. Use "CERTIFICATE" as the block type for certificates, and "RSA PRIVATE
+// KEY", "EC PRIVATE KEY" or "PRIVATE KEY" for PKCS1, EC and PKCS8 keys.
+func DERToPEM(derBytes []byte, blockType string) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: derBytes})
+}
+
+// PEMToDER returns the DER data of the first PEM block, whatever its type
+func PEMToDER(pemBytes []byte) ([]byte, error) {
+	b, _ := pem.Decode(pemBytes)
+	if b == nil {
+		return nil, errNoPEMBlock
+	}
+
+	if len(b.Bytes) == 0 {
+		return nil, fmt.Errorf("privatetls: empty %s PEM block", b.Type)
+	}
+
+	return b.Bytes, nil
+}
+
+// CertToBase64DER returns the standard base64 encoding of the DER encoded
+// certificate, on a single line and without PEM armor, the format of headers
+// such as X-Client-Cert set by mTLS proxies, and of many JSON configurations
+func CertToBase64DER(cert *x509.Certificate) string {
+	return base64.StdEncoding.EncodeToString(cert.Raw)
+}
+
+// Base64DERToCert parses a certificate encoded by CertToBase64DER. Surrounding
+// whitespace and missing padding are tolerated.
+func Base64DERToCert(s string) (*x509.Certificate, error) {
+	der, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(strings.TrimSpace(s), "="))
+	if err != nil {
+		return nil, fmt.Errorf("privatetls: decoding base64 certificate: %w", err)
+	}
+
+	return x509.ParseCertificate(der)
+}
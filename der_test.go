@@ -0,0 +1,168 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCertToDER(t *testing.T) {
+	rsaCert, err := NewCert(WithRSAKeyBits(1024))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	ecdsaCert, err := NewCert(WithECDSACurve(elliptic.P256()))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	tests := []struct {
+		cert     tls.Certificate
+		keyType  string
+		parseKey func([]byte) (interface{}, error)
+	}{
+		{rsaCert, "RSA PRIVATE KEY", func(der []byte) (interface{}, error) { return x509.ParsePKCS1PrivateKey(der) }},
+		{ecdsaCert, "PRIVATE KEY", x509.ParsePKCS8PrivateKey},
+	}
+
+	for _, tt := range tests {
+		certDER, keyDER, err := CertToDER(tt.cert)
+
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+
+		if _, err := tt.parseKey(keyDER); err != nil {
+			t.Errorf("Unexpected error parsing the %s: %v\n", tt.keyType, err)
+		}
+
+		// DER -> PEM -> DER must round-trip, and the PEM must load as a key pair
+		certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+		keyPEM := pem.EncodeToMemory(&pem.Block{Type: tt.keyType, Bytes: keyDER})
+
+		if b, _ := pem.Decode(certPEM); !bytes.Equal(b.Bytes, certDER) {
+			t.Error("Certificate DER did not round-trip through PEM")
+		}
+
+		if b, _ := pem.Decode(keyPEM); !bytes.Equal(b.Bytes, keyDER) {
+			t.Error("Key DER did not round-trip through PEM")
+		}
+
+		if _, err := tls.X509KeyPair(certPEM, keyPEM); err != nil {
+			t.Errorf("Unexpected error: %v\n", err)
+		}
+	}
+
+	if _, _, err := CertToDER(tls.Certificate{}); err == nil {
+		t.Error("Expected an error for an empty certificate")
+	}
+}
+
+func TestWriteDERFiles(t *testing.T) {
+	cert, err := NewCert(WithEd25519())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "cert.der")
+	keyPath := filepath.Join(dir, "key.der")
+
+	if err := WriteDERFiles(certPath, keyPath, cert); err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	certDER, err := os.ReadFile(certPath)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if !bytes.Equal(certDER, cert.Certificate[0]) {
+		t.Error("Written certificate does not match")
+	}
+
+	if fi, err := os.Stat(keyPath); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("Expected the key file to have mode 0600: %v\n", err)
+	}
+}
+
+func TestDERToPEMRoundTrip(t *testing.T) {
+	for _, opt := range []Option{WithRSAKeyBits(1024), WithECDSACurve(elliptic.P256()), WithEd25519()} {
+		certPEM, keyPEM, err := NewCertPEM(opt)
+
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+
+		for _, data := range [][]byte{certPEM, keyPEM} {
+			b, _ := pem.Decode(data)
+			der, err := PEMToDER(data)
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+
+			if !bytes.Equal(DERToPEM(der, b.Type), data) {
+				t.Errorf("Expected the %s block to round-trip\n", b.Type)
+			}
+		}
+	}
+
+	if _, err := PEMToDER([]byte("garbage")); err == nil {
+		t.Error("Expected an error for data that is not PEM encoded")
+	}
+}
+
+func TestBase64DERRoundTrip(t *testing.T) {
+	cert, err := NewCertEd25519()
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	encoded := CertToBase64DER(cert.Leaf)
+
+	if strings.ContainsAny(encoded, "\n-") {
+		t.Errorf("Unexpected line breaks or armor: %q\n", encoded)
+	}
+
+	for _, s := range []string{encoded, " " + strings.TrimRight(encoded, "=") + "\n"} {
+		parsed, err := Base64DERToCert(s)
+
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+
+		if !parsed.Equal(cert.Leaf) {
+			t.Error("Parsed certificate does not match the original")
+		}
+	}
+
+	if _, err := Base64DERToCert("not base64!"); err == nil {
+		t.Error("Expected an error for invalid base64")
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto/tls"
+	"errors"
+	mathrand "math/rand"
+	"time"
+)
+
+// The fixed validity period of deterministic certificates, unless set by options
+var (
+	deterministicNotBefore = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	deterministicNotAfter  = time.Date(2124, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// NewCertDeterministic Generates the same certificate for the same seed and
+// options on every run, for golden files and snapshot tests. The randomness
+// comes from math/rand seeded with seed, and the validity period is fixed to
+// 2024-01-01 UTC through 2124-01-01 UTC unless set with WithNotBefore or
+// WithValidity. Validity periods shorter than the time since NotBefore produce
+// expired certificates.
+//
+// The key is Ed25519, since the RSA and ECDSA key generation and signatures
+// of crypto/rsa and crypto/ecdsa are deliberately not reproducible; selecting
+// another key type is an error.
+//
+// The keys are predictable from the seed: never use deterministic
+// certificates outside of tests.
+func NewCertDeterministic(seed int64, opts ...Option) (tls.Certificate, error) {
+	cfg := defaultCertConfig()
+	cfg.keyType = KeyTypeEd25519
+	cfg.notBefore = deterministicNotBefore
+	cfg.validFor = deterministicNotAfter.Sub(deterministicNotBefore)
+	cfg = applyOptions(cfg, opts)
+
+	if cfg.keyType != KeyTypeEd25519 {
+		return tls.Certificate{}, errors.New("privatetls: deterministic certificates require Ed25519 keys")
+	}
+
+	cfg.random = mathrand.New(mathrand.NewSource(seed))
+
+	return newCert(cfg)
+}
@@ -0,0 +1,59 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNewCertDeterministic(t *testing.T) {
+	a, err := NewCertDeterministic(42, WithDNSNames("golden.internal"))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	b, err := NewCertDeterministic(42, WithDNSNames("golden.internal"))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if !bytes.Equal(a.Certificate[0], b.Certificate[0]) {
+		t.Error("Expected identical certificates for the same seed")
+	}
+
+	if !a.Leaf.NotBefore.Equal(deterministicNotBefore) {
+		t.Errorf("Unexpected NotBefore: %v\n", a.Leaf.NotBefore)
+	}
+
+	if CertIsExpired(a) || !a.Leaf.NotAfter.Equal(deterministicNotAfter) {
+		t.Errorf("Expected the default certificate to be valid until %v, got %v\n", deterministicNotAfter, a.Leaf.NotAfter)
+	}
+
+	c, err := NewCertDeterministic(43, WithDNSNames("golden.internal"))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if bytes.Equal(a.Certificate[0], c.Certificate[0]) {
+		t.Error("Expected different certificates for different seeds")
+	}
+
+	if _, err := NewCertDeterministic(42, WithRSAKeyBits(2048)); err == nil {
+		t.Error("Expected an error for an RSA key")
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+)
+
+// ExportEncryptedKey PEM encodes the private key like WriteCertFiles does, and
+// encrypts the block with the password using the given cipher, e.g.
+// x509.PEMCipherAES256.
+//
+// This uses the legacy OpenSSL PEM encryption, which is understood by
+// OpenSSL and most servers, but is not authenticated: it protects keys at
+// rest, not against tampering.
+func ExportEncryptedKey(key crypto.PrivateKey, password []byte, alg x509.PEMCipher) ([]byte, error) {
+	keyPEM, err := encodePrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return encryptKeyPEM(keyPEM, password, alg)
+}
+
+// LoadEncryptedKey decrypts a private key encrypted by ExportEncryptedKey
+func LoadEncryptedKey(pemBlock []byte, password []byte) (crypto.PrivateKey, error) {
+	b, _ := pem.Decode(pemBlock)
+	if b == nil {
+		return nil, errNoPEMBlock
+	}
+
+	if !x509.IsEncryptedPEMBlock(b) {
+		return nil, errors.New("privatetls: private key is not encrypted")
+	}
+
+	der, err := x509.DecryptPEMBlock(b, password)
+	if err != nil {
+		return nil, err
+	}
+
+	return parsePrivateKey(der)
+}
+
+// Encrypt the PEM-encoded private key with the password
+func encryptKeyPEM(keyPEM, password []byte, alg x509.PEMCipher) ([]byte, error) {
+	if len(password) == 0 {
+		return nil, errors.New("privatetls: password must not be empty")
+	}
+
+	b, _ := pem.Decode(keyPEM)
+
+	encrypted, err := x509.EncryptPEMBlock(rand.Reader, b.Type, b.Bytes, password, alg)
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(encrypted), nil
+}
@@ -0,0 +1,89 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncryptedKey(t *testing.T) {
+	cert, err := NewCert(WithECDSACurve(elliptic.P256()))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	keyPEM, err := ExportEncryptedKey(cert.PrivateKey, []byte("secret"), x509.PEMCipherAES256)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	key, err := LoadEncryptedKey(keyPEM, []byte("secret"))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if !key.(*ecdsa.PrivateKey).Equal(cert.PrivateKey) {
+		t.Error("Decrypted key does not match the original key")
+	}
+
+	if _, err := LoadEncryptedKey(keyPEM, []byte("wrong")); err == nil {
+		t.Error("Expected an error for a wrong password")
+	}
+
+	if _, err := ExportEncryptedKey(cert.PrivateKey, nil, x509.PEMCipherAES256); err == nil {
+		t.Error("Expected an error for an empty password")
+	}
+}
+
+func TestWithEncryptedKey(t *testing.T) {
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+
+	if err := WriteCertFiles(certPath, keyPath, WithEd25519(), WithEncryptedKey([]byte("secret"))); err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	keyPEM, err := os.ReadFile(keyPath)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if b, _ := pem.Decode(keyPEM); b == nil || !x509.IsEncryptedPEMBlock(b) {
+		t.Fatal("Expected an encrypted key file")
+	}
+
+	if _, err := LoadEncryptedKey(keyPEM, []byte("secret")); err != nil {
+		t.Errorf("Unexpected error: %v\n", err)
+	}
+
+	// NewCert keeps working, since it never exposes the PEM key
+	if _, err := NewCert(WithEd25519(), WithEncryptedKey([]byte("secret"))); err != nil {
+		t.Errorf("Unexpected error: %v\n", err)
+	}
+
+	if _, _, err := NewCertPEM(WithEd25519(), WithEncryptedKey([]byte{})); err == nil {
+		t.Error("Expected an error for an empty password")
+	}
+}
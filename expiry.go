@@ -0,0 +1,88 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"time"
+)
+
+// ErrCertExpired is returned for a certificate that is past its NotAfter time
+var ErrCertExpired = errors.New("privatetls: certificate has expired")
+
+// CertExpiresIn returns the time left until the leaf certificate expires.
+// For an expired certificate the duration is negative and the error is
+// ErrCertExpired.
+func CertExpiresIn(cert tls.Certificate) (time.Duration, error) {
+	leaf, err := leafCertificate(cert)
+	if err != nil {
+		return 0, err
+	}
+
+	d := time.Until(leaf.NotAfter)
+	if d < 0 {
+		return d, ErrCertExpired
+	}
+
+	return d, nil
+}
+
+// CertIsExpired reports whether the leaf certificate is past its NotAfter time.
+// A certificate that cannot be parsed is reported as expired, since it should
+// be regenerated either way.
+func CertIsExpired(cert tls.Certificate) bool {
+	_, err := CertExpiresIn(cert)
+
+	return err != nil
+}
+
+// ExpiryNotifier returns a channel that is closed when the certificate gets
+// within notifyBefore of its expiry, immediately if it already is, and a
+// function that stops the notification. After stopping, the channel is never
+// closed. Use it to react to expiry in your own way, e.g. reload a
+// configuration or raise an alert; see AutoRenew for automatic renewals.
+func ExpiryNotifier(cert tls.Certificate, notifyBefore time.Duration) (<-chan struct{}, func(), error) {
+	leaf, err := leafCertificate(cert)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if notifyBefore < 0 {
+		return nil, nil, errors.New("privatetls: notification period must not be negative")
+	}
+
+	ch := make(chan struct{})
+	timer := time.AfterFunc(time.Until(leaf.NotAfter)-notifyBefore, func() {
+		close(ch)
+	})
+
+	return ch, func() { timer.Stop() }, nil
+}
+
+var errEmptyChain = errors.New("privatetls: certificate chain is empty")
+
+// Return the parsed leaf of a TLS certificate, parsing it if necessary
+func leafCertificate(cert tls.Certificate) (*x509.Certificate, error) {
+	if cert.Leaf != nil {
+		return cert.Leaf, nil
+	}
+
+	if len(cert.Certificate) == 0 {
+		return nil, errEmptyChain
+	}
+
+	return x509.ParseCertificate(cert.Certificate[0])
+}
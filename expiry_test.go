@@ -0,0 +1,116 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto/tls"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCertExpiresIn(t *testing.T) {
+	cert, err := NewCert(WithEd25519(), WithValidity(time.Hour), WithNotBeforeOffset(0))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	d, err := CertExpiresIn(cert)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if d <= 59*time.Minute || d > time.Hour {
+		t.Errorf("Certificate expires in %v, expected about an hour\n", d)
+	}
+
+	if CertIsExpired(cert) {
+		t.Error("Expected the certificate not to be expired")
+	}
+}
+
+func TestCertExpiresInExpired(t *testing.T) {
+	cert := expiredCert(t)
+
+	d, err := CertExpiresIn(cert)
+
+	if !errors.Is(err, ErrCertExpired) {
+		t.Errorf("Expected ErrCertExpired, got %v\n", err)
+	}
+
+	if d >= 0 {
+		t.Errorf("Expected a negative duration, got %v\n", d)
+	}
+
+	if !CertIsExpired(cert) {
+		t.Error("Expected the certificate to be expired")
+	}
+
+	if !CertIsExpired(tls.Certificate{}) {
+		t.Error("Expected an empty certificate to be reported as expired")
+	}
+}
+
+// Create a self-signed certificate that expired an hour ago
+func expiredCert(t *testing.T) tls.Certificate {
+	t.Helper()
+
+	cert, err := NewCert(WithEd25519(), WithNotBefore(time.Now().Add(-2*time.Hour)), WithValidity(time.Hour))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	return cert
+}
+
+func TestExpiryNotifier(t *testing.T) {
+	cert, err := NewCert(WithEd25519(), WithValidity(time.Hour), WithNotBeforeOffset(0))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	ch, stop, err := ExpiryNotifier(cert, time.Hour-100*time.Millisecond)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	defer stop()
+
+	select {
+	case <-ch:
+	case <-time.After(5 * time.Second):
+		t.Error("Expected an expiry notification")
+	}
+
+	ch, stop, err = ExpiryNotifier(cert, time.Hour-100*time.Millisecond)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	stop()
+
+	select {
+	case <-ch:
+		t.Error("Unexpected notification after stopping")
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	if _, _, err := ExpiryNotifier(tls.Certificate{}, time.Minute); err == nil {
+		t.Error("Expected an error for an empty certificate")
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+)
+
+// Certificate policy OIDs of the CA/Browser Forum baseline requirements
+var (
+	OIDDomainValidated       = asn1.ObjectIdentifier{2, 23, 140, 1, 2, 1}
+	OIDOrganizationValidated = asn1.ObjectIdentifier{2, 23, 140, 1, 2, 2}
+	OIDExtendedValidation    = asn1.ObjectIdentifier{2, 23, 140, 1, 1}
+)
+
+// WithCertificatePolicies sets the certificate policy OIDs, e.g.
+// OIDDomainValidated, for environments that check them
+func WithCertificatePolicies(oids ...asn1.ObjectIdentifier) Option {
+	return func(c *certConfig) {
+		c.policyIdentifiers = oids
+	}
+}
+
+// WithExtraExtensions adds custom X.509 extensions to the certificate, e.g.
+// device attestation or proprietary policy OIDs. Verifiers reject
+// certificates with critical extensions they do not understand, including Go
+// itself, so only mark an extension critical if every verifier handles it.
+func WithExtraExtensions(exts ...pkix.Extension) Option {
+	return func(c *certConfig) {
+		c.extraExtensions = append(c.extraExtensions, exts...)
+	}
+}
+
+// NewExtension ASN.1 encodes the value, as encoding/asn1 does, and returns a
+// non-critical extension with the OID, ready for WithExtraExtensions
+func NewExtension(oid asn1.ObjectIdentifier, value interface{}) (pkix.Extension, error) {
+	der, err := asn1.Marshal(value)
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+
+	return pkix.Extension{Id: oid, Value: der}, nil
+}
@@ -0,0 +1,64 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"encoding/asn1"
+	"testing"
+)
+
+func TestWithExtraExtensions(t *testing.T) {
+	oid := asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 55555, 1}
+	ext, err := NewExtension(oid, "device-42")
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	cert, err := NewCert(WithEd25519(), WithExtraExtensions(ext))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	var value string
+	for _, e := range cert.Leaf.Extensions {
+		if e.Id.Equal(oid) {
+			if _, err := asn1.Unmarshal(e.Value, &value); err != nil {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+		}
+	}
+
+	if value != "device-42" {
+		t.Errorf("Expected the custom extension, got %q\n", value)
+	}
+
+	if _, err := NewExtension(oid, make(chan int)); err == nil {
+		t.Error("Expected an error for a value that cannot be encoded")
+	}
+}
+
+func TestWithCertificatePolicies(t *testing.T) {
+	cert, err := NewCert(WithEd25519(), WithCertificatePolicies(OIDDomainValidated, OIDExtendedValidation))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	got := cert.Leaf.PolicyIdentifiers
+	if len(got) != 2 || !got[0].Equal(OIDDomainValidated) || !got[1].Equal(OIDExtendedValidation) {
+		t.Errorf("Unexpected policies: %v\n", got)
+	}
+}
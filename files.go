@@ -0,0 +1,161 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	certFileMode = 0644
+	keyFileMode  = 0600
+)
+
+// WriteCertFiles Generates a self-signed certificate like NewCert and writes
+// the PEM-encoded certificate to certPath and private key to keyPath. The key
+// file is only readable by its owner. The files can be loaded back with
+// tls.LoadX509KeyPair.
+func WriteCertFiles(certPath, keyPath string, opts ...Option) error {
+	certPEM, keyPEM, err := NewCertPEM(opts...)
+	if err != nil {
+		return err
+	}
+
+	return writeCertAndKeyFiles(certPath, keyPath, certPEM, keyPEM)
+}
+
+// LoadCert Loads a PEM-encoded certificate and private key, e.g. written by
+// WriteCertFiles. In addition to the checks done by tls.LoadX509KeyPair it
+// reports empty files and certificates that are already expired, in which
+// case the error wraps ErrCertExpired.
+func LoadCert(certPath, keyPath string) (tls.Certificate, error) {
+	certPEM, err := readNonEmptyFile(certPath)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("privatetls: reading certificate file: %w", err)
+	}
+
+	keyPEM, err := readNonEmptyFile(keyPath)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("privatetls: reading key file: %w", err)
+	}
+
+	// This also verifies that the key matches the certificate's public key
+	cert, err := x509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("privatetls: loading %s and %s: %w", certPath, keyPath, err)
+	}
+
+	if d, err := CertExpiresIn(cert); err != nil {
+		return tls.Certificate{}, fmt.Errorf("%w: %s expired %v ago", err, certPath, -d.Round(time.Second))
+	}
+
+	return cert, nil
+}
+
+// Read a file, treating an empty file as an error
+func readNonEmptyFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%s is empty", path)
+	}
+
+	return data, nil
+}
+
+// WriteDERFiles writes the DER encoded leaf certificate and private key
+// returned by CertToDER to certPath and keyPath. The key file is only
+// readable by its owner.
+func WriteDERFiles(certPath, keyPath string, cert tls.Certificate) error {
+	certDER, keyDER, err := CertToDER(cert)
+	if err != nil {
+		return err
+	}
+
+	return writeCertAndKeyFiles(certPath, keyPath, certDER, keyDER)
+}
+
+// Write a certificate and key pair. Both are written to temporary files
+// first, and only renamed into place once both writes succeeded, so that a
+// failed write leaves any previous pair intact.
+func writeCertAndKeyFiles(certPath, keyPath string, certPEM, keyPEM []byte) error {
+	keyTmp, err := writeTempFile(keyPath, keyPEM, keyFileMode)
+	if err != nil {
+		return fmt.Errorf("privatetls: writing key file: %w", err)
+	}
+	defer os.Remove(keyTmp)
+
+	certTmp, err := writeTempFile(certPath, certPEM, certFileMode)
+	if err != nil {
+		return fmt.Errorf("privatetls: writing certificate file: %w", err)
+	}
+	defer os.Remove(certTmp)
+
+	if err := os.Rename(keyTmp, keyPath); err != nil {
+		return fmt.Errorf("privatetls: writing key file: %w", err)
+	}
+
+	if err := os.Rename(certTmp, certPath); err != nil {
+		return fmt.Errorf("privatetls: writing certificate file: %w", err)
+	}
+
+	return nil
+}
+
+// Write the data to a temporary file next to path and rename it into place,
+// so that a failed write never leaves a partial file behind
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmpPath, err := writeTempFile(path, data, perm)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath)
+
+	return os.Rename(tmpPath, path)
+}
+
+// Write the data to a new temporary file next to path, returning its name.
+// The file is removed again if the write fails.
+func writeTempFile(path string, data []byte, perm os.FileMode) (string, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return "", err
+	}
+
+	tmpPath := f.Name()
+
+	if err = f.Chmod(perm); err == nil {
+		if _, err = f.Write(data); err == nil {
+			err = f.Sync()
+		}
+	}
+
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+
+	return tmpPath, nil
+}
@@ -0,0 +1,163 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto/tls"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteCertFiles(t *testing.T) {
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+
+	if err := WriteCertFiles(certPath, keyPath, WithEd25519()); err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	for path, mode := range map[string]os.FileMode{certPath: 0644, keyPath: 0600} {
+		fi, err := os.Stat(path)
+
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+
+		if fi.Mode().Perm() != mode {
+			t.Errorf("File %s has mode %v, expected %v\n", path, fi.Mode().Perm(), mode)
+		}
+	}
+
+	if _, err := tls.LoadX509KeyPair(certPath, keyPath); err != nil {
+		t.Errorf("Unexpected error: %v\n", err)
+	}
+}
+
+func TestWriteCertFilesFailure(t *testing.T) {
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "key.pem")
+
+	// The certificate directory does not exist, so the key must be removed
+	err := WriteCertFiles(filepath.Join(dir, "missing", "cert.pem"), keyPath, WithEd25519())
+
+	if err == nil {
+		t.Fatal("Expected an error for a missing directory")
+	}
+
+	if _, err := os.Stat(keyPath); !os.IsNotExist(err) {
+		t.Errorf("Expected the key file to be removed, got %v\n", err)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Errorf("Expected no leftover files, found %d\n", len(entries))
+	}
+}
+
+func TestLoadCert(t *testing.T) {
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+
+	if err := WriteCertFiles(certPath, keyPath, WithEd25519()); err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if _, err := LoadCert(certPath, keyPath); err != nil {
+		t.Errorf("Unexpected error: %v\n", err)
+	}
+}
+
+func TestLoadCertInvalid(t *testing.T) {
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+	otherCertPath := filepath.Join(dir, "other-cert.pem")
+	otherKeyPath := filepath.Join(dir, "other-key.pem")
+	emptyPath := filepath.Join(dir, "empty.pem")
+
+	if err := WriteCertFiles(certPath, keyPath, WithEd25519()); err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if err := WriteCertFiles(otherCertPath, otherKeyPath, WithEd25519()); err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if err := os.WriteFile(emptyPath, nil, 0600); err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	tests := []struct {
+		name, certPath, keyPath string
+	}{
+		{"missing certificate", filepath.Join(dir, "missing.pem"), keyPath},
+		{"empty certificate", emptyPath, keyPath},
+		{"empty key", certPath, emptyPath},
+		{"mismatched key", certPath, otherKeyPath},
+	}
+
+	for _, tt := range tests {
+		if _, err := LoadCert(tt.certPath, tt.keyPath); err == nil {
+			t.Errorf("Expected an error for %s\n", tt.name)
+		}
+	}
+}
+
+func TestLoadCertExpired(t *testing.T) {
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+
+	certPEM, keyPEM, err := encodeTLSCertificate(expiredCert(t))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if err := writeCertAndKeyFiles(certPath, keyPath, certPEM, keyPEM); err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if _, err := LoadCert(certPath, keyPath); !errors.Is(err, ErrCertExpired) {
+		t.Errorf("Expected ErrCertExpired, got %v\n", err)
+	}
+}
+
+func TestWriteCertAndKeyFilesFailure(t *testing.T) {
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "key.pem")
+
+	if err := os.WriteFile(keyPath, []byte("previous key"), keyFileMode); err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	// The certificate cannot be written into a missing directory
+	certPath := filepath.Join(dir, "missing", "cert.pem")
+
+	if err := writeCertAndKeyFiles(certPath, keyPath, []byte("cert"), []byte("new key")); err == nil {
+		t.Fatal("Expected an error for an unwritable certificate")
+	}
+
+	if b, _ := os.ReadFile(keyPath); string(b) != "previous key" {
+		t.Errorf("Expected the previous key to be kept, got %q\n", b)
+	}
+
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("Expected no temporary files to be left behind, got %d entries\n", len(entries))
+	}
+}
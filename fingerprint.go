@@ -0,0 +1,67 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto"
+	_ "crypto/sha1" // Register the hash functions commonly used for fingerprints
+	_ "crypto/sha256"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Fingerprint returns the hash of the DER encoded certificate as colon
+// separated pairs of upper case hex digits, the format displayed by browsers
+// and openssl x509 -fingerprint.
+func Fingerprint(cert *x509.Certificate, hash crypto.Hash) (string, error) {
+	if cert == nil {
+		return "", errors.New("privatetls: certificate must not be nil")
+	}
+
+	if !hash.Available() {
+		return "", fmt.Errorf("privatetls: hash function %v is not available", hash)
+	}
+
+	h := hash.New()
+	h.Write(cert.Raw)
+
+	return formatFingerprint(h.Sum(nil)), nil
+}
+
+// FingerprintSHA256 returns the SHA-256 fingerprint of the certificate, see
+// Fingerprint. It panics if cert is nil.
+func FingerprintSHA256(cert *x509.Certificate) string {
+	fp, err := Fingerprint(cert, crypto.SHA256)
+	if err != nil {
+		panic(err)
+	}
+
+	return fp
+}
+
+// Format a digest as AB:CD:...
+func formatFingerprint(sum []byte) string {
+	var b strings.Builder
+
+	for i, c := range sum {
+		if i > 0 {
+			b.WriteByte(':')
+		}
+		fmt.Fprintf(&b, "%02X", c)
+	}
+
+	return b.String()
+}
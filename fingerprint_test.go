@@ -0,0 +1,79 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	cert, err := NewCert(WithEd25519())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	sha256Sum := sha256.Sum256(cert.Leaf.Raw)
+	sha1Sum := sha1.Sum(cert.Leaf.Raw)
+
+	tests := []struct {
+		hash crypto.Hash
+		sum  []byte
+	}{
+		{crypto.SHA256, sha256Sum[:]},
+		{crypto.SHA1, sha1Sum[:]},
+	}
+
+	for _, tt := range tests {
+		fp, err := Fingerprint(cert.Leaf, tt.hash)
+
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+
+		if got := strings.ReplaceAll(fp, ":", ""); got != strings.ToUpper(hex.EncodeToString(tt.sum)) {
+			t.Errorf("Unexpected %v fingerprint: %s\n", tt.hash, fp)
+		}
+
+		if len(fp) != len(tt.sum)*3-1 {
+			t.Errorf("Unexpected fingerprint format: %s\n", fp)
+		}
+	}
+
+	if FingerprintSHA256(cert.Leaf) != mustFingerprint(t, cert.Leaf.Raw) {
+		t.Error("FingerprintSHA256 does not match Fingerprint with crypto.SHA256")
+	}
+
+	if _, err := Fingerprint(nil, crypto.SHA256); err == nil {
+		t.Error("Expected an error for a nil certificate")
+	}
+
+	if _, err := Fingerprint(cert.Leaf, crypto.Hash(0)); err == nil {
+		t.Error("Expected an error for an unavailable hash")
+	}
+}
+
+// The expected SHA-256 fingerprint of the DER bytes
+func mustFingerprint(t *testing.T, der []byte) string {
+	t.Helper()
+
+	sum := sha256.Sum256(der)
+
+	return formatFingerprint(sum[:])
+}
@@ -0,0 +1,71 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package grpccreds provides gRPC transport credentials backed by certificates
+// generated with privatetls. It lives in its own package so that importing
+// privatetls does not pull gRPC into the import graph.
+package grpccreds
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+
+	"github.com/netbucket/privatetls"
+	"google.golang.org/grpc/credentials"
+)
+
+// NewGRPCServerCredentials generates a self-signed certificate with
+// privatetls.NewCert and returns server credentials that present it.
+// Clients have to trust the certificate to connect; when they need it,
+// generate the certificate with privatetls.NewCert and use
+// NewGRPCServerCredentialsFromCert instead.
+func NewGRPCServerCredentials(opts ...privatetls.Option) (credentials.TransportCredentials, error) {
+	cert, err := privatetls.NewCert(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewGRPCServerCredentialsFromCert(cert), nil
+}
+
+// NewGRPCServerCredentialsFromCert returns server credentials that present the
+// supplied certificate
+func NewGRPCServerCredentialsFromCert(cert tls.Certificate) credentials.TransportCredentials {
+	return credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{cert},
+	})
+}
+
+// NewGRPCClientCredentials returns client credentials that trust caCert, e.g.
+// the self-signed server certificate or the CA that issued it. A nil caCert
+// falls back to the system roots. When options are supplied, a client
+// certificate is generated with privatetls.NewCert and presented to servers
+// that request one.
+func NewGRPCClientCredentials(caCert *x509.Certificate, opts ...privatetls.Option) (credentials.TransportCredentials, error) {
+	cfg := &tls.Config{}
+
+	if caCert != nil {
+		cfg.RootCAs = x509.NewCertPool()
+		cfg.RootCAs.AddCert(caCert)
+	}
+
+	if len(opts) > 0 {
+		cert, err := privatetls.NewCert(opts...)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return credentials.NewTLS(cfg), nil
+}
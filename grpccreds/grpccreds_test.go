@@ -0,0 +1,84 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpccreds
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/netbucket/privatetls"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestGRPCCredentials(t *testing.T) {
+	cert, err := privatetls.NewCert(privatetls.WithEd25519())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	s := grpc.NewServer(grpc.Creds(NewGRPCServerCredentialsFromCert(cert)))
+	healthpb.RegisterHealthServer(s, health.NewServer())
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	go s.Serve(l)
+	defer s.Stop()
+
+	creds, err := NewGRPCClientCredentials(cert.Leaf)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	conn, err := grpc.NewClient(l.Addr().String(), grpc.WithTransportCredentials(creds))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("Unexpected health status: %v\n", resp.Status)
+	}
+}
+
+func TestNewGRPCServerCredentials(t *testing.T) {
+	creds, err := NewGRPCServerCredentials(privatetls.WithEd25519())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if creds.Info().SecurityProtocol != "tls" {
+		t.Errorf("Unexpected security protocol: %s\n", creds.Info().SecurityProtocol)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package h3 serves HTTP/3 over QUIC with certificates generated by
+// privatetls. It lives in its own package so that importing privatetls does
+// not pull quic-go into the import graph.
+package h3
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+
+	"github.com/netbucket/privatetls"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// StartH3Listener serves HTTP/3 on the UDP address udpAddr, with a self-signed
+// certificate generated by privatetls.NewCert for the names derived from the
+// address, see privatetls.AutoSANFromAddress, and the options. Since clients
+// discover HTTP/3 through the Alt-Svc header, an HTTPS listener on the same
+// TCP port serves HTTP/1.1 and HTTP/2, announcing HTTP/3 in its responses. A
+// nil handler falls back to http.DefaultServeMux. It returns when either
+// listener fails.
+func StartH3Listener(udpAddr string, handler http.Handler, opts ...privatetls.Option) error {
+	if udpAddr == "" {
+		udpAddr = ":https"
+	}
+
+	cert, err := privatetls.NewCert(append(privatetls.AutoSANFromAddress(udpAddr), opts...)...)
+	if err != nil {
+		return err
+	}
+
+	if handler == nil {
+		handler = http.DefaultServeMux
+	}
+
+	addr, err := net.ResolveUDPAddr("udp", udpAddr)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	cfg := &tls.Config{Certificates: []tls.Certificate{cert}}
+
+	// ConfigureTLSConfig sets the h3 ALPN protocol
+	quicServer := &http3.Server{
+		Handler:   handler,
+		TLSConfig: http3.ConfigureTLSConfig(cfg),
+	}
+
+	tcpServer := &http.Server{
+		Addr:      udpAddr,
+		TLSConfig: cfg,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			quicServer.SetQUICHeaders(w.Header())
+			handler.ServeHTTP(w, r)
+		}),
+	}
+
+	tcpErr := make(chan error, 1)
+	quicErr := make(chan error, 1)
+
+	go func() {
+		tcpErr <- tcpServer.ListenAndServeTLS("", "")
+	}()
+	go func() {
+		quicErr <- quicServer.Serve(conn)
+	}()
+
+	select {
+	case err = <-tcpErr:
+		quicServer.Close()
+	case err = <-quicErr:
+		tcpServer.Close()
+	}
+
+	return err
+}
@@ -0,0 +1,79 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package h3
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+func TestStartH3Listener(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.Proto)
+	})
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- StartH3Listener(addr, handler)
+	}()
+
+	// The listeners use a fresh self-signed certificate, skip its verification
+	tcpClient := http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+
+	var resp *http.Response
+	deadline := time.Now().Add(10 * time.Second)
+	for resp, err = tcpClient.Get("https://" + addr); err != nil && time.Now().Before(deadline); resp, err = tcpClient.Get("https://" + addr) {
+		select {
+		case err := <-errCh:
+			t.Fatalf("Unexpected error: %v\n", err)
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	resp.Body.Close()
+
+	if resp.Header.Get("Alt-Svc") == "" {
+		t.Error("Expected the HTTPS listener to announce HTTP/3")
+	}
+
+	h3Client := http.Client{Transport: &http3.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	resp, err = h3Client.Get("https://" + addr)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	defer resp.Body.Close()
+
+	if body, _ := ioutil.ReadAll(resp.Body); string(body) != "HTTP/3.0" {
+		t.Errorf("Unexpected protocol: %q\n", body)
+	}
+}
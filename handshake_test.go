@@ -0,0 +1,40 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"testing"
+)
+
+func TestMeasureHandshakeLatency(t *testing.T) {
+	cert, err := NewCert(WithEd25519())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	latency, err := MeasureHandshakeLatency(cert, 5)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if latency <= 0 {
+		t.Errorf("Unexpected latency: %v\n", latency)
+	}
+
+	if _, err := MeasureHandshakeLatency(cert, 0); err == nil {
+		t.Error("Expected an error for no iterations")
+	}
+}
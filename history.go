@@ -0,0 +1,164 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// CertHistory is a FileCertStore that keeps every certificate it replaced or
+// deleted, so that it can later be established which certificate was served
+// at a given time. The retired certificates, without their keys, are kept as
+// <dir>/archive/<serial>.crt, with the serial number in decimal. It is safe
+// for concurrent use.
+type CertHistory struct {
+	*FileCertStore
+
+	archiveDir string
+	mu         sync.Mutex
+}
+
+// NewCertHistory creates a certificate store with an archive in the directory,
+// creating both if they do not exist
+func NewCertHistory(dir string) (*CertHistory, error) {
+	store, err := NewFileCertStore(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	archiveDir := filepath.Join(dir, "archive")
+	if err := os.MkdirAll(archiveDir, 0700); err != nil {
+		return nil, fmt.Errorf("privatetls: creating certificate archive: %w", err)
+	}
+
+	return &CertHistory{FileCertStore: store, archiveDir: archiveDir}, nil
+}
+
+// Store saves the certificate under the name, archiving the certificate it
+// replaces. Storing the same certificate again archives nothing.
+func (h *CertHistory) Store(name string, cert tls.Certificate) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(cert.Certificate) == 0 {
+		return errEmptyChain
+	}
+
+	if err := h.archive(name, cert.Certificate[0]); err != nil {
+		return err
+	}
+
+	return h.FileCertStore.Store(name, cert)
+}
+
+// Delete removes the certificate saved under the name, archiving it first
+func (h *CertHistory) Delete(name string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := h.archive(name, nil); err != nil {
+		return err
+	}
+
+	return h.FileCertStore.Delete(name)
+}
+
+// List returns the metadata of the archived certificates, ordered by their
+// NotBefore time, oldest first
+func (h *CertHistory) List() ([]CertInfo, error) {
+	entries, err := os.ReadDir(h.archiveDir)
+	if err != nil {
+		return nil, fmt.Errorf("privatetls: reading certificate archive: %w", err)
+	}
+
+	var infos []CertInfo
+
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".crt") {
+			continue
+		}
+
+		cert, err := h.loadArchived(filepath.Join(h.archiveDir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		info, err := InspectCert(tls.Certificate{Certificate: [][]byte{cert.Raw}, Leaf: cert})
+		if err != nil {
+			return nil, err
+		}
+
+		infos = append(infos, info)
+	}
+
+	sort.SliceStable(infos, func(i, j int) bool {
+		return infos[i].NotBefore.Before(infos[j].NotBefore)
+	})
+
+	return infos, nil
+}
+
+// LoadBySerial returns the archived certificate with the serial number
+func (h *CertHistory) LoadBySerial(serial *big.Int) (*x509.Certificate, error) {
+	if serial == nil || serial.Sign() <= 0 {
+		return nil, errors.New("privatetls: serial number must be positive")
+	}
+
+	return h.loadArchived(h.archivePath(serial))
+}
+
+// Copy the certificate currently saved under the name into the archive,
+// unless it is the certificate being stored
+func (h *CertHistory) archive(name string, replacement []byte) error {
+	current, ok := h.FileCertStore.Load(name)
+	if !ok || bytes.Equal(current.Certificate[0], replacement) {
+		return nil
+	}
+
+	leaf, err := leafCertificate(current)
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(h.archivePath(leaf.SerialNumber), CertificateToPEM(leaf), certFileMode); err != nil {
+		return fmt.Errorf("privatetls: archiving certificate %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// The archive file of a serial number
+func (h *CertHistory) archivePath(serial *big.Int) string {
+	return filepath.Join(h.archiveDir, serial.String()+".crt")
+}
+
+// Read and parse an archived certificate
+func (h *CertHistory) loadArchived(path string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("privatetls: reading archived certificate: %w", err)
+	}
+
+	return PEMToCertificate(data)
+}
@@ -0,0 +1,98 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"bytes"
+	"crypto/tls"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestCertHistory(t *testing.T) {
+	h, err := NewCertHistory(t.TempDir())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	first, err := NewCert(WithEd25519(), WithNotBefore(time.Now().Add(-2*time.Hour)))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	second, err := NewCert(WithEd25519(), WithNotBefore(time.Now().Add(-time.Hour)))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	third, err := NewCert(WithEd25519())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	for _, cert := range []tls.Certificate{first, first, second, third} {
+		if err := h.Store("localhost", cert); err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+	}
+
+	current, ok := h.Load("localhost")
+
+	if !ok || !bytes.Equal(current.Certificate[0], third.Certificate[0]) {
+		t.Error("Expected the last stored certificate to be current")
+	}
+
+	infos, err := h.List()
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if len(infos) != 2 {
+		t.Fatalf("Expected 2 archived certificates, got %d\n", len(infos))
+	}
+
+	for i, cert := range []tls.Certificate{first, second} {
+		if infos[i].SerialNumber.Cmp(cert.Leaf.SerialNumber) != 0 {
+			t.Errorf("Expected archived certificate %d to be serial %v, got %v\n", i, cert.Leaf.SerialNumber, infos[i].SerialNumber)
+		}
+	}
+
+	archived, err := h.LoadBySerial(second.Leaf.SerialNumber)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if !bytes.Equal(archived.Raw, second.Certificate[0]) {
+		t.Error("Expected the archived certificate to match the stored one")
+	}
+
+	if _, err := h.LoadBySerial(big.NewInt(1)); err == nil {
+		t.Error("Expected an error for an unknown serial number")
+	}
+
+	if err := h.Delete("localhost"); err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if _, err := h.LoadBySerial(third.Leaf.SerialNumber); err != nil {
+		t.Errorf("Expected the deleted certificate to be archived: %v\n", err)
+	}
+}
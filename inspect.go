@@ -0,0 +1,165 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"time"
+)
+
+// CertInfo summarizes the metadata of a certificate
+type CertInfo struct {
+	Subject            pkix.Name
+	SANs               []string // DNS names, email addresses and URIs
+	IPAddresses        []net.IP
+	NotBefore          time.Time
+	NotAfter           time.Time
+	IsCA               bool
+	SignatureAlgorithm string
+	KeyType            string // RSA, ECDSA or Ed25519
+	KeyBits            int
+	SerialNumber       *big.Int
+	Fingerprint        string // SHA-256, see FingerprintSHA256
+}
+
+// InspectCert returns the metadata of the leaf certificate
+func InspectCert(cert tls.Certificate) (CertInfo, error) {
+	leaf, err := leafCertificate(cert)
+	if err != nil {
+		return CertInfo{}, err
+	}
+
+	info := CertInfo{
+		Subject:            leaf.Subject,
+		IPAddresses:        leaf.IPAddresses,
+		NotBefore:          leaf.NotBefore,
+		NotAfter:           leaf.NotAfter,
+		IsCA:               leaf.IsCA,
+		SignatureAlgorithm: leaf.SignatureAlgorithm.String(),
+		SerialNumber:       leaf.SerialNumber,
+		Fingerprint:        FingerprintSHA256(leaf),
+	}
+
+	info.SANs = append(info.SANs, leaf.DNSNames...)
+	info.SANs = append(info.SANs, leaf.EmailAddresses...)
+	for _, u := range leaf.URIs {
+		info.SANs = append(info.SANs, u.String())
+	}
+
+	switch k := leaf.PublicKey.(type) {
+	case *rsa.PublicKey:
+		info.KeyType, info.KeyBits = "RSA", k.N.BitLen()
+	case *ecdsa.PublicKey:
+		info.KeyType, info.KeyBits = "ECDSA", k.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		info.KeyType, info.KeyBits = "Ed25519", 256
+	default:
+		info.KeyType = leaf.PublicKeyAlgorithm.String()
+	}
+
+	return info, nil
+}
+
+// ExtractSANs returns all the SANs of the certificate in a uniform format,
+// prefixed by their type: "dns:example.com", "ip:10.0.0.5",
+// "email:user@example.com" and "uri:spiffe://example.com/service", in that
+// order of types
+func ExtractSANs(cert *x509.Certificate) []string {
+	var sans []string
+
+	for _, name := range cert.DNSNames {
+		sans = append(sans, "dns:"+name)
+	}
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, "ip:"+ip.String())
+	}
+	for _, email := range cert.EmailAddresses {
+		sans = append(sans, "email:"+email)
+	}
+	for _, u := range cert.URIs {
+		sans = append(sans, "uri:"+u.String())
+	}
+
+	return sans
+}
+
+// HasSAN reports whether the certificate has the SAN, in the format of
+// ExtractSANs. DNS names are compared case-insensitively and IP addresses by
+// value, so "ip:::1" matches "ip:0:0:0:0:0:0:0:1".
+func HasSAN(cert *x509.Certificate, san string) bool {
+	kind, value, ok := strings.Cut(san, ":")
+	if !ok {
+		return false
+	}
+
+	for _, s := range ExtractSANs(cert) {
+		k, v, _ := strings.Cut(s, ":")
+		if k != strings.ToLower(kind) {
+			continue
+		}
+
+		switch k {
+		case "dns":
+			if strings.EqualFold(v, value) {
+				return true
+			}
+		case "ip":
+			if ip := net.ParseIP(value); ip != nil && ip.Equal(net.ParseIP(v)) {
+				return true
+			}
+		default:
+			if v == value {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// String formats the metadata for humans, in the layout of openssl x509 -text
+func (i CertInfo) String() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Certificate:\n")
+	fmt.Fprintf(&b, "    Serial Number: %v\n", i.SerialNumber)
+	fmt.Fprintf(&b, "    Signature Algorithm: %s\n", i.SignatureAlgorithm)
+	fmt.Fprintf(&b, "    Validity\n")
+	fmt.Fprintf(&b, "        Not Before: %s\n", i.NotBefore.UTC().Format(time.RFC1123))
+	fmt.Fprintf(&b, "        Not After : %s\n", i.NotAfter.UTC().Format(time.RFC1123))
+	fmt.Fprintf(&b, "    Subject: %s\n", i.Subject)
+	fmt.Fprintf(&b, "    Public Key Algorithm: %s (%d bit)\n", i.KeyType, i.KeyBits)
+	fmt.Fprintf(&b, "    Basic Constraints: CA:%s\n", strings.ToUpper(fmt.Sprint(i.IsCA)))
+
+	if len(i.SANs) > 0 || len(i.IPAddresses) > 0 {
+		names := append([]string(nil), i.SANs...)
+		for _, ip := range i.IPAddresses {
+			names = append(names, ip.String())
+		}
+		fmt.Fprintf(&b, "    Subject Alternative Name:\n        %s\n", strings.Join(names, ", "))
+	}
+
+	fmt.Fprintf(&b, "    SHA256 Fingerprint=%s\n", i.Fingerprint)
+
+	return b.String()
+}
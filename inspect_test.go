@@ -0,0 +1,82 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestInspectCert(t *testing.T) {
+	cert, err := NewCert(WithRSAKeyBits(1024), WithCommonName("inspect.internal"), WithDNSNames("inspect.internal"))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	info, err := InspectCert(cert)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if info.KeyType != "RSA" || info.KeyBits != 1024 || !info.IsCA || info.Subject.CommonName != "inspect.internal" {
+		t.Errorf("Unexpected info: %+v\n", info)
+	}
+
+	if len(info.SANs) != 1 || info.SANs[0] != "inspect.internal" || len(info.IPAddresses) != 2 {
+		t.Errorf("Unexpected SANs: %v %v\n", info.SANs, info.IPAddresses)
+	}
+
+	if info.Fingerprint != FingerprintSHA256(cert.Leaf) {
+		t.Errorf("Unexpected fingerprint: %s\n", info.Fingerprint)
+	}
+
+	s := info.String()
+	for _, want := range []string{"Public Key Algorithm: RSA (1024 bit)", "CA:TRUE", "inspect.internal, 127.0.0.1, ::1", "SHA256-RSA"} {
+		if !strings.Contains(s, want) {
+			t.Errorf("Expected %q in:\n%s", want, s)
+		}
+	}
+}
+
+func TestExtractSANs(t *testing.T) {
+	u, _ := url.Parse("spiffe://example.com/service")
+
+	cert, err := NewCert(WithEd25519(), WithDNSNames("Example.com"), WithIPAddresses(net.ParseIP("::1")),
+		WithEmailAddresses("user@example.com"), WithURISANs(u))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	want := []string{"dns:Example.com", "ip:::1", "email:user@example.com", "uri:spiffe://example.com/service"}
+	if got := ExtractSANs(cert.Leaf); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("Unexpected SANs: %v\n", got)
+	}
+
+	for _, san := range []string{"dns:example.COM", "ip:0:0:0:0:0:0:0:1", "email:user@example.com", "URI:spiffe://example.com/service"} {
+		if !HasSAN(cert.Leaf, san) {
+			t.Errorf("Expected the certificate to have %s\n", san)
+		}
+	}
+
+	for _, san := range []string{"dns:other.com", "ip:127.0.0.1", "email:other@example.com", "example.com"} {
+		if HasSAN(cert.Leaf, san) {
+			t.Errorf("Expected the certificate not to have %s\n", san)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"fmt"
+)
+
+var errKeyMismatch = errors.New("privatetls: private key does not match the certificate public key")
+
+// ValidateKeyPair checks that the private key belongs to the certificate, by
+// comparing the marshaled public keys. Use it when assembling a
+// tls.Certificate by hand: a mismatched pair otherwise only shows as a
+// cryptic handshake failure.
+func ValidateKeyPair(cert *x509.Certificate, key crypto.PrivateKey) error {
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return errNotASigner
+	}
+
+	certPub, err := x509.MarshalPKIXPublicKey(cert.PublicKey)
+	if err != nil {
+		return fmt.Errorf("privatetls: unsupported certificate public key: %w", err)
+	}
+
+	keyPub, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		return fmt.Errorf("privatetls: unsupported private key: %w", err)
+	}
+
+	if !bytes.Equal(certPub, keyPub) {
+		return errKeyMismatch
+	}
+
+	return nil
+}
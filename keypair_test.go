@@ -0,0 +1,42 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import "testing"
+
+func TestValidateKeyPair(t *testing.T) {
+	cert, err := NewCertEd25519()
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	other, err := NewCertEd25519()
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if err := ValidateKeyPair(cert.Leaf, cert.PrivateKey); err != nil {
+		t.Errorf("Unexpected error: %v\n", err)
+	}
+
+	if err := ValidateKeyPair(cert.Leaf, other.PrivateKey); err != errKeyMismatch {
+		t.Errorf("Expected a key mismatch, got %v\n", err)
+	}
+
+	if err := ValidateKeyPair(cert.Leaf, "not a key"); err == nil {
+		t.Error("Expected an error for an invalid key")
+	}
+}
@@ -0,0 +1,317 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	minSerialNumberBits = 64
+	maxSerialNumberLen  = 20 // In octets, per RFC 5280
+	rsaPublicExponent   = 65537
+)
+
+// LintLevel is the severity of a LintResult
+type LintLevel int
+
+// The lint severities, in decreasing order
+const (
+	// LintError marks a certificate that clients will reject or that violates RFC 5280
+	LintError LintLevel = iota
+	// LintWarning marks a likely mistake or a certificate some clients will reject
+	LintWarning
+	// LintNotice marks a questionable but possibly intended choice
+	LintNotice
+)
+
+// String returns the name of the lint level
+func (l LintLevel) String() string {
+	switch l {
+	case LintError:
+		return "error"
+	case LintWarning:
+		return "warning"
+	case LintNotice:
+		return "notice"
+	default:
+		return fmt.Sprintf("LintLevel(%d)", int(l))
+	}
+}
+
+// LintResult describes a problem found by LintCert. The Code is stable and
+// prefixed with the level, e.g. "e_no_san" or "w_validity_exceeds_398_days",
+// while the Message is meant for humans.
+type LintResult struct {
+	Level   LintLevel
+	Code    string
+	Message string
+}
+
+// A lint check returns a message describing the problem, or the empty string
+type lintRule struct {
+	level LintLevel
+	code  string
+	check func(*x509.Certificate) string
+}
+
+var lintRules = []lintRule{
+	{LintError, "e_no_san", lintNoSAN},
+	{LintError, "e_not_after_before_not_before", lintValidityOrder},
+	{LintError, "e_deprecated_signature_algorithm", lintSignatureAlgorithm},
+	{LintError, "e_rsa_key_too_small", lintRSAKeySize},
+	{LintError, "e_ca_basic_constraints_missing", lintCABasicConstraints},
+	{LintError, "e_ca_missing_cert_sign", lintCACertSign},
+	{LintError, "e_key_usage_incompatible_with_ext_key_usage", lintKeyUsageEKU},
+	{LintError, "e_serial_number_not_positive", lintSerialPositive},
+	{LintError, "e_serial_number_longer_than_20_octets", lintSerialLength},
+	{LintError, "e_leaf_has_path_length", lintLeafPathLen},
+	{LintError, "e_dns_name_malformed", lintDNSNames},
+	{LintWarning, "w_validity_exceeds_398_days", lintValidityLength},
+	{LintWarning, "w_expired", lintExpired},
+	{LintWarning, "w_not_yet_valid", lintNotYetValid},
+	{LintWarning, "w_serial_number_low_entropy", lintSerialEntropy},
+	{LintWarning, "w_subject_cn_not_in_san", lintCommonName},
+	{LintWarning, "w_key_usage_missing", lintKeyUsageMissing},
+	{LintWarning, "w_ext_key_usage_any", lintEKUAny},
+	{LintNotice, "n_rsa_public_exponent_not_65537", lintRSAExponent},
+	{LintNotice, "n_ed25519_limited_client_support", lintEd25519},
+	{LintNotice, "n_ca_used_as_leaf", lintCAAsLeaf},
+}
+
+// LintCert checks the certificate for common mistakes, such as missing SANs,
+// deprecated algorithms, short keys or contradicting key usages, returning
+// the problems found ordered by level. A nil result means no problems. The
+// checks are a small, self-contained subset of what zlint offers.
+func LintCert(cert *x509.Certificate) []LintResult {
+	var results []LintResult
+
+	for _, r := range lintRules {
+		if msg := r.check(cert); msg != "" {
+			results = append(results, LintResult{Level: r.level, Code: r.code, Message: msg})
+		}
+	}
+
+	return results
+}
+
+func lintNoSAN(c *x509.Certificate) string {
+	if c.IsCA || len(c.DNSNames)+len(c.IPAddresses)+len(c.URIs)+len(c.EmailAddresses) > 0 {
+		return ""
+	}
+	return "certificate has no subject alternative names, which TLS clients require"
+}
+
+func lintValidityOrder(c *x509.Certificate) string {
+	if !c.NotAfter.Before(c.NotBefore) {
+		return ""
+	}
+	return fmt.Sprintf("NotAfter %v is before NotBefore %v", c.NotAfter, c.NotBefore)
+}
+
+func lintSignatureAlgorithm(c *x509.Certificate) string {
+	if !isSHA1Signature(c.SignatureAlgorithm) {
+		return ""
+	}
+	return fmt.Sprintf("signature algorithm %v is deprecated and rejected by modern clients", c.SignatureAlgorithm)
+}
+
+func lintRSAKeySize(c *x509.Certificate) string {
+	k, ok := c.PublicKey.(*rsa.PublicKey)
+	if !ok || k.N.BitLen() >= minProfileRSAKeyLength {
+		return ""
+	}
+	return fmt.Sprintf("RSA key length %d is below %d bits", k.N.BitLen(), minProfileRSAKeyLength)
+}
+
+func lintCABasicConstraints(c *x509.Certificate) string {
+	if c.KeyUsage&x509.KeyUsageCertSign == 0 || (c.BasicConstraintsValid && c.IsCA) {
+		return ""
+	}
+	return "key usage allows certificate signing, but basic constraints do not mark the certificate as a CA"
+}
+
+func lintCACertSign(c *x509.Certificate) string {
+	if !c.IsCA || c.KeyUsage == 0 || c.KeyUsage&x509.KeyUsageCertSign != 0 {
+		return ""
+	}
+	return "CA certificate key usage does not allow certificate signing"
+}
+
+func lintKeyUsageEKU(c *x509.Certificate) string {
+	const tlsUsages = x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment | x509.KeyUsageKeyAgreement
+
+	if c.KeyUsage == 0 || c.KeyUsage&tlsUsages != 0 {
+		return ""
+	}
+
+	for _, u := range c.ExtKeyUsage {
+		if u == x509.ExtKeyUsageServerAuth || u == x509.ExtKeyUsageClientAuth {
+			return "extended key usage allows TLS authentication, but key usage allows neither signatures nor key exchange"
+		}
+	}
+	return ""
+}
+
+func lintSerialPositive(c *x509.Certificate) string {
+	if c.SerialNumber != nil && c.SerialNumber.Sign() > 0 {
+		return ""
+	}
+	return "serial number must be a positive integer"
+}
+
+func lintSerialLength(c *x509.Certificate) string {
+	// One more octet is allowed for the sign, since the serial is positive
+	if c.SerialNumber == nil || c.SerialNumber.BitLen() < maxSerialNumberLen*8 {
+		return ""
+	}
+	return fmt.Sprintf("serial number of %d bits does not fit into %d octets", c.SerialNumber.BitLen(), maxSerialNumberLen)
+}
+
+func lintLeafPathLen(c *x509.Certificate) string {
+	if c.IsCA || c.MaxPathLen <= 0 {
+		return ""
+	}
+	return "path length constraint is set on a certificate that is not a CA"
+}
+
+func lintDNSNames(c *x509.Certificate) string {
+	for _, name := range c.DNSNames {
+		if !isDNSName(name) {
+			return fmt.Sprintf("DNS name %q is malformed", name)
+		}
+	}
+	return ""
+}
+
+func lintValidityLength(c *x509.Certificate) string {
+	if c.IsCA && len(c.DNSNames)+len(c.IPAddresses) == 0 {
+		return ""
+	}
+
+	validity := c.NotAfter.Sub(c.NotBefore)
+	if validity <= maxBrowserValidity {
+		return ""
+	}
+	return fmt.Sprintf("validity of %d days exceeds the 398 days accepted by browsers", validity/(24*time.Hour))
+}
+
+func lintExpired(c *x509.Certificate) string {
+	if time.Now().Before(c.NotAfter) {
+		return ""
+	}
+	return fmt.Sprintf("certificate expired at %v", c.NotAfter)
+}
+
+func lintNotYetValid(c *x509.Certificate) string {
+	if !time.Now().Before(c.NotBefore) {
+		return ""
+	}
+	return fmt.Sprintf("certificate is not valid before %v", c.NotBefore)
+}
+
+func lintSerialEntropy(c *x509.Certificate) string {
+	if c.SerialNumber == nil || c.SerialNumber.Sign() <= 0 || c.SerialNumber.BitLen() >= minSerialNumberBits {
+		return ""
+	}
+	return fmt.Sprintf("serial number has %d bits, at least %d random bits are recommended", c.SerialNumber.BitLen(), minSerialNumberBits)
+}
+
+func lintCommonName(c *x509.Certificate) string {
+	cn := c.Subject.CommonName
+	if cn == "" || c.IsCA {
+		return ""
+	}
+
+	for _, name := range c.DNSNames {
+		if strings.EqualFold(name, cn) {
+			return ""
+		}
+	}
+
+	if ip := net.ParseIP(cn); ip != nil {
+		for _, addr := range c.IPAddresses {
+			if addr.Equal(ip) {
+				return ""
+			}
+		}
+	}
+	return fmt.Sprintf("subject common name %q is not among the SANs, and is ignored by modern clients", cn)
+}
+
+func lintKeyUsageMissing(c *x509.Certificate) string {
+	if c.KeyUsage != 0 {
+		return ""
+	}
+	return "certificate has no key usage extension"
+}
+
+func lintEKUAny(c *x509.Certificate) string {
+	for _, u := range c.ExtKeyUsage {
+		if u == x509.ExtKeyUsageAny {
+			return "extended key usage allows any purpose"
+		}
+	}
+	return ""
+}
+
+func lintRSAExponent(c *x509.Certificate) string {
+	k, ok := c.PublicKey.(*rsa.PublicKey)
+	if !ok || k.E == rsaPublicExponent {
+		return ""
+	}
+	return fmt.Sprintf("RSA public exponent %d is not the customary 65537", k.E)
+}
+
+func lintEd25519(c *x509.Certificate) string {
+	if _, ok := c.PublicKey.(ed25519.PublicKey); !ok {
+		return ""
+	}
+	return "Ed25519 keys are not supported by browsers"
+}
+
+func lintCAAsLeaf(c *x509.Certificate) string {
+	if !c.IsCA || len(c.DNSNames)+len(c.IPAddresses) == 0 {
+		return ""
+	}
+	return "CA certificate also names servers; fine for self-signed development certificates, a private CA should issue separate leaves"
+}
+
+// A syntactic check of a DNS name, allowing a wildcard as the leftmost label
+func isDNSName(name string) bool {
+	name = strings.TrimPrefix(name, "*.")
+	if name == "" || len(name) > 253 {
+		return false
+	}
+
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,91 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestLintCert(t *testing.T) {
+	cert, err := NewCert(WithEd25519())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	got := lintCodes(LintCert(cert.Leaf))
+	want := []string{"n_ed25519_limited_client_support", "n_ca_used_as_leaf"}
+
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("Expected %v, got %v\n", want, got)
+	}
+}
+
+func TestLintCertProblems(t *testing.T) {
+	now := time.Now()
+	cert := &x509.Certificate{
+		SerialNumber:       big.NewInt(1),
+		Subject:            pkix.Name{CommonName: "service.internal"},
+		NotBefore:          now.Add(-time.Hour),
+		NotAfter:           now.Add(2 * 365 * 24 * time.Hour),
+		SignatureAlgorithm: x509.SHA1WithRSA,
+		KeyUsage:           x509.KeyUsageCertSign,
+		ExtKeyUsage:        []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:           []string{"bad..name"},
+	}
+
+	results := LintCert(cert)
+	codes := lintCodes(results)
+
+	for _, want := range []string{
+		"e_deprecated_signature_algorithm",
+		"e_ca_basic_constraints_missing",
+		"e_key_usage_incompatible_with_ext_key_usage",
+		"e_dns_name_malformed",
+		"w_validity_exceeds_398_days",
+		"w_serial_number_low_entropy",
+		"w_subject_cn_not_in_san",
+	} {
+		if !containsString(codes, want) {
+			t.Errorf("Expected %s among %v\n", want, codes)
+		}
+	}
+
+	for i := 1; i < len(results); i++ {
+		if results[i].Level < results[i-1].Level {
+			t.Errorf("Results are not ordered by level: %v\n", codes)
+		}
+	}
+}
+
+func lintCodes(results []LintResult) []string {
+	var codes []string
+	for _, r := range results {
+		codes = append(codes, r.Code)
+	}
+	return codes
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
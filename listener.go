@@ -0,0 +1,297 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+)
+
+// TLSWrap wraps any listener, e.g. a TCP or Unix socket listener, so that
+// accepted connections are TLS server connections presenting the
+// certificate. Use it with frameworks that accept a net.Listener rather than
+// an address.
+func TLSWrap(inner net.Listener, cert tls.Certificate) (net.Listener, error) {
+	if inner == nil {
+		return nil, errors.New("privatetls: listener must not be nil")
+	}
+
+	if len(cert.Certificate) == 0 {
+		return nil, errEmptyChain
+	}
+
+	return tls.NewListener(inner, &tls.Config{Certificates: []tls.Certificate{cert}}), nil
+}
+
+// NewRateLimitedTLSListener wraps a listener so that connections are accepted
+// at most maxConnsPerSecond times per second on average, with bursts of up to
+// a second worth of connections. Accept blocks until the rate allows another
+// connection, leaving the clients in the listen backlog meanwhile. Wrapping a
+// TLS listener bounds the handshakes the server performs, which protects its
+// CPU from clients repeating expensive, e.g. RSA, handshakes. A non-positive
+// rate disables the limit.
+func NewRateLimitedTLSListener(inner net.Listener, maxConnsPerSecond float64) net.Listener {
+	if maxConnsPerSecond <= 0 {
+		return inner
+	}
+
+	burst := max(maxConnsPerSecond, 1)
+
+	return &rateLimitedListener{
+		Listener: inner,
+		rate:     maxConnsPerSecond,
+		burst:    burst,
+		tokens:   burst,
+		last:     time.Now(),
+		done:     make(chan struct{}),
+	}
+}
+
+// A listener throttled by a token bucket, refilled at rate tokens per second
+type rateLimitedListener struct {
+	net.Listener
+	rate, burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Accept waits for a token, then for a connection
+func (l *rateLimitedListener) Accept() (net.Conn, error) {
+	if err := l.wait(); err != nil {
+		return nil, err
+	}
+
+	return l.Listener.Accept()
+}
+
+// Close unblocks the pending Accept calls and closes the inner listener
+func (l *rateLimitedListener) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.done)
+	})
+
+	return l.Listener.Close()
+}
+
+// Take a token, waiting for the bucket to refill if it is empty
+func (l *rateLimitedListener) wait() error {
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+		l.last = now
+
+		if l.tokens >= 1 {
+			l.tokens--
+			l.mu.Unlock()
+			return nil
+		}
+
+		delay := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		l.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-l.done:
+			timer.Stop()
+			return net.ErrClosed
+		case <-timer.C:
+		}
+	}
+}
+
+// How long NewLoggingListener waits for a client to complete its handshake
+const loggingHandshakeTimeout = 10 * time.Second
+
+// NewLoggingListener wraps a TLS listener, e.g. one created with TLSWrap, so
+// that the outcome of every handshake is logged: the TLS version, cipher
+// suite, ALPN protocol, SNI name, client certificate fingerprint and the time
+// the handshake took at Info level, or the error at Warn level when it fails.
+// A nil logger logs to slog.Default().
+//
+// The listener completes the handshakes itself, concurrently and within 10
+// seconds, and Accept returns the *tls.Conn once its handshake succeeded, so
+// it works with an http.Server, which then sees the negotiated parameters as
+// usual. Connections failing the handshake are closed, and connections that
+// are not TLS are passed through unlogged.
+func NewLoggingListener(inner net.Listener, logger *slog.Logger) net.Listener {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	l := &loggingListener{
+		Listener: inner,
+		logger:   logger,
+		conns:    make(chan net.Conn),
+		errs:     make(chan error),
+		done:     make(chan struct{}),
+		pending:  make(map[net.Conn]struct{}),
+	}
+
+	go l.acceptLoop()
+
+	return l
+}
+
+// A listener handing out TLS connections once their handshake is logged
+type loggingListener struct {
+	net.Listener
+	logger *slog.Logger
+
+	conns     chan net.Conn
+	errs      chan error
+	done      chan struct{}
+	closeOnce sync.Once
+
+	mu      sync.Mutex
+	pending map[net.Conn]struct{} // Still handshaking, nil once closed
+}
+
+// Accept returns the next connection that completed its handshake
+func (l *loggingListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case err := <-l.errs:
+		return nil, err
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close stops accepting connections, and closes those still handshaking
+func (l *loggingListener) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.done)
+
+		l.mu.Lock()
+		pending := l.pending
+		l.pending = nil
+		l.mu.Unlock()
+
+		for conn := range pending {
+			conn.Close()
+		}
+	})
+
+	return l.Listener.Close()
+}
+
+// Record a connection until its handshake is over, or close it if the
+// listener is closed already
+func (l *loggingListener) track(conn net.Conn) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.pending == nil {
+		conn.Close()
+		return false
+	}
+
+	l.pending[conn] = struct{}{}
+	return true
+}
+
+func (l *loggingListener) untrack(conn net.Conn) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.pending, conn)
+}
+
+// Accept connections from the inner listener, handshaking each one
+// concurrently, until it is closed
+func (l *loggingListener) acceptLoop() {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			select {
+			case l.errs <- err:
+			case <-l.done:
+				return
+			}
+
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+
+		tlsConn, ok := conn.(*tls.Conn)
+		if !ok {
+			l.deliver(conn)
+			continue
+		}
+
+		if l.track(tlsConn) {
+			go l.handshake(tlsConn)
+		}
+	}
+}
+
+// Complete and log the handshake, handing out the connection if it succeeded
+func (l *loggingListener) handshake(conn *tls.Conn) {
+	conn.SetDeadline(time.Now().Add(loggingHandshakeTimeout))
+
+	start := time.Now()
+	err := conn.Handshake()
+	took := time.Since(start)
+
+	l.untrack(conn)
+	conn.SetDeadline(time.Time{})
+
+	remote := conn.RemoteAddr().String()
+	if err != nil {
+		l.logger.Warn("privatetls: TLS handshake failed", "remote", remote, "error", err, "took", took)
+		conn.Close()
+		return
+	}
+
+	state := conn.ConnectionState()
+	attrs := []any{
+		"remote", remote,
+		"version", tls.VersionName(state.Version),
+		"cipher_suite", tls.CipherSuiteName(state.CipherSuite),
+		"alpn", state.NegotiatedProtocol,
+		"server_name", state.ServerName,
+		"resumed", state.DidResume,
+		"took", took,
+	}
+
+	if len(state.PeerCertificates) > 0 {
+		attrs = append(attrs, "peer_fingerprint", FingerprintSHA256(state.PeerCertificates[0]))
+	}
+
+	l.logger.Info("privatetls: TLS handshake completed", attrs...)
+
+	l.deliver(conn)
+}
+
+// Hand the connection to Accept, or close it if the listener is closed
+func (l *loggingListener) deliver(conn net.Conn) {
+	select {
+	case l.conns <- conn:
+	case <-l.done:
+		conn.Close()
+	}
+}
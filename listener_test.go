@@ -0,0 +1,246 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTLSWrap(t *testing.T) {
+	cert, err := NewCertEd25519()
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	for _, network := range []string{"tcp", "unix"} {
+		addr := "127.0.0.1:0"
+		if network == "unix" {
+			addr = filepath.Join(t.TempDir(), "tls.sock")
+		}
+
+		inner, err := net.Listen(network, addr)
+
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+
+		l, err := TLSWrap(inner, cert)
+
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+
+		go func() {
+			conn, err := l.Accept()
+			if err == nil {
+				io.WriteString(conn, "hello")
+				conn.Close()
+			}
+		}()
+
+		roots := x509.NewCertPool()
+		roots.AddCert(cert.Leaf)
+
+		conn, err := tls.Dial(network, inner.Addr().String(), &tls.Config{RootCAs: roots, ServerName: "localhost"})
+
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v\n", network, err)
+		}
+
+		if b, _ := io.ReadAll(conn); string(b) != "hello" {
+			t.Errorf("%s: unexpected response %q\n", network, b)
+		}
+
+		conn.Close()
+		l.Close()
+	}
+
+	if _, err := TLSWrap(nil, cert); err == nil {
+		t.Error("Expected an error for a nil listener")
+	}
+}
+
+func TestNewRateLimitedTLSListener(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	l := NewRateLimitedTLSListener(inner, 20)
+	defer l.Close()
+
+	// The first 20 connections are a burst, the next 10 take half a second
+	const conns = 30
+	for i := 0; i < conns; i++ {
+		conn, err := net.Dial("tcp", inner.Addr().String())
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+		defer conn.Close()
+	}
+
+	start := time.Now()
+	for i := 0; i < conns; i++ {
+		conn, err := l.Accept()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+		conn.Close()
+	}
+
+	if took := time.Since(start); took < 400*time.Millisecond {
+		t.Errorf("Connections were accepted too fast: %v\n", took)
+	}
+}
+
+func TestNewRateLimitedTLSListenerClose(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if NewRateLimitedTLSListener(inner, 0) != inner {
+		t.Error("Expected no limit for a zero rate")
+	}
+
+	l := NewRateLimitedTLSListener(inner, 0.001)
+	l.(*rateLimitedListener).tokens = 0
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := l.Accept()
+		errCh <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	l.Close()
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, net.ErrClosed) {
+			t.Errorf("Unexpected error: %v\n", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Accept was not unblocked by Close")
+	}
+}
+
+func TestNewLoggingListener(t *testing.T) {
+	cert, err := NewCertEd25519()
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	tlsListener, err := TLSWrap(inner, cert)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	var logs syncBuffer
+	l := NewLoggingListener(tlsListener, slog.New(slog.NewTextHandler(&logs, nil)))
+
+	// An http.Server sees the TLS connections as usual
+	s := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || !r.TLS.HandshakeComplete {
+			http.Error(w, "no TLS", http.StatusInternalServerError)
+			return
+		}
+		io.WriteString(w, "hello")
+	})}
+	go s.Serve(l)
+	defer s.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert.Leaf)
+
+	client := http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, ServerName: "localhost"}}}
+	resp, err := client.Get("https://" + inner.Addr().String())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if b, _ := io.ReadAll(resp.Body); string(b) != "hello" {
+		t.Errorf("Unexpected response %q\n", b)
+	}
+	resp.Body.Close()
+
+	// A client that does not speak TLS
+	plain, err := net.Dial("tcp", inner.Addr().String())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	io.WriteString(plain, "GET / HTTP/1.0\r\n\r\n")
+	io.ReadAll(plain)
+	plain.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) && !strings.Contains(logs.String(), "TLS handshake failed") {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for _, want := range []string{"TLS handshake completed", "version=\"TLS 1.3\"", "server_name=localhost", "took=",
+		"level=WARN", "TLS handshake failed"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("Expected %q in the logs:\n%s", want, logs.String())
+		}
+	}
+
+	// A client that never starts its handshake
+	stalled, err := net.Dial("tcp", inner.Addr().String())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	defer stalled.Close()
+
+	// Let the listener accept it
+	time.Sleep(100 * time.Millisecond)
+
+	if err := l.Close(); err != nil {
+		t.Errorf("Unexpected error: %v\n", err)
+	}
+
+	// Close drops the connections still handshaking, well before their timeout
+	stalled.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := stalled.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("Expected the handshaking connection to be closed, got %v\n", err)
+	}
+
+	if _, err := l.Accept(); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Expected net.ErrClosed after Close, got %v\n", err)
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metrics exports the lifecycle of privatetls managed certificates,
+// see privatetls.AutoRenew, as Prometheus metrics. It lives in its own package
+// so that only its users depend on the Prometheus client.
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/netbucket/privatetls"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// InstrumentWithPrometheus registers the certificate metrics with reg and
+// installs an observer, see privatetls.SetObserver, feeding them:
+//
+//   - privatetls_cert_expiry_seconds, a gauge of the time until each managed
+//     certificate expires, labeled with its name
+//   - privatetls_cert_generation_duration_seconds, a histogram of the time
+//     taken to generate certificates
+//   - privatetls_cert_renewals_total, a counter of successful renewals,
+//     labeled with the certificate name
+func InstrumentWithPrometheus(reg prometheus.Registerer) error {
+	c := newCollector()
+
+	for _, m := range []prometheus.Collector{c, c.generation, c.renewals} {
+		if err := reg.Register(m); err != nil {
+			return err
+		}
+	}
+
+	privatetls.SetObserver(c)
+
+	return nil
+}
+
+// Turns certificate events into metrics. The expiry gauge is computed at
+// collection time, so that it keeps decreasing between events.
+type collector struct {
+	expiry     *prometheus.Desc
+	generation prometheus.Histogram
+	renewals   *prometheus.CounterVec
+
+	mu       sync.Mutex
+	notAfter map[string]time.Time
+}
+
+func newCollector() *collector {
+	return &collector{
+		expiry: prometheus.NewDesc("privatetls_cert_expiry_seconds",
+			"Time until the managed certificate expires.", []string{"name"}, nil),
+		generation: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "privatetls_cert_generation_duration_seconds",
+			Help:    "Time taken to generate a managed certificate.",
+			Buckets: prometheus.ExponentialBuckets(0.001, 4, 8),
+		}),
+		renewals: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "privatetls_cert_renewals_total",
+			Help: "Number of successful managed certificate renewals.",
+		}, []string{"name"}),
+		notAfter: make(map[string]time.Time),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.expiry
+}
+
+// Collect implements prometheus.Collector
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for name, notAfter := range c.notAfter {
+		ch <- prometheus.MustNewConstMetric(c.expiry, prometheus.GaugeValue, time.Until(notAfter).Seconds(), name)
+	}
+}
+
+// CertGenerated implements privatetls.Observer
+func (c *collector) CertGenerated(name string, notAfter time.Time, took time.Duration) {
+	c.generation.Observe(took.Seconds())
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.notAfter[name] = notAfter
+}
+
+// CertRenewed implements privatetls.Observer
+func (c *collector) CertRenewed(name string) {
+	c.renewals.WithLabelValues(name).Inc()
+}
+
+// CertClosed implements privatetls.Observer
+func (c *collector) CertClosed(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.notAfter, name)
+}
@@ -0,0 +1,115 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"crypto/tls"
+	"testing"
+	"time"
+
+	"github.com/netbucket/privatetls"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestInstrumentWithPrometheus(t *testing.T) {
+	reg := prometheus.NewRegistry()
+
+	if err := InstrumentWithPrometheus(reg); err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	defer privatetls.SetObserver(nil)
+
+	gen := func() (tls.Certificate, error) {
+		return privatetls.NewCert(privatetls.WithEd25519(), privatetls.WithCommonName("metrics-test"),
+			privatetls.WithValidity(3*time.Second), privatetls.WithNotBeforeOffset(0))
+	}
+
+	m, err := privatetls.AutoRenew(2*time.Second, gen)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	defer m.Close()
+
+	if n := testutil.CollectAndCount(reg, "privatetls_cert_expiry_seconds"); n != 1 {
+		t.Errorf("Expected 1 expiry gauge, got %d\n", n)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if testutil.CollectAndCount(reg, "privatetls_cert_renewals_total") == 1 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	c, err := reg.Gather()
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	for _, mf := range c {
+		if mf.GetName() == "privatetls_cert_renewals_total" && mf.Metric[0].GetCounter().GetValue() >= 1 {
+			if got := mf.Metric[0].Label[0].GetValue(); got != "metrics-test" {
+				t.Errorf("Unexpected certificate name %q\n", got)
+			}
+			return
+		}
+	}
+
+	t.Error("Expected a renewal to be counted")
+}
+
+func TestInstrumentWithPrometheusDefaultNames(t *testing.T) {
+	reg := prometheus.NewRegistry()
+
+	if err := InstrumentWithPrometheus(reg); err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	defer privatetls.SetObserver(nil)
+
+	gen := func() (tls.Certificate, error) {
+		return privatetls.NewCert(privatetls.WithEd25519())
+	}
+
+	first, err := privatetls.AutoRenew(time.Hour, gen)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	defer first.Close()
+
+	second, err := privatetls.AutoRenew(time.Hour, gen)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if first.Name() == second.Name() {
+		t.Errorf("Expected distinct names, got %q twice\n", first.Name())
+	}
+
+	if n := testutil.CollectAndCount(reg, "privatetls_cert_expiry_seconds"); n != 2 {
+		t.Errorf("Expected 2 expiry gauges, got %d\n", n)
+	}
+
+	// Closing one certificate keeps the gauge of the other
+	second.Close()
+
+	if n := testutil.CollectAndCount(reg, "privatetls_cert_expiry_seconds"); n != 1 {
+		t.Errorf("Expected 1 expiry gauge, got %d\n", n)
+	}
+}
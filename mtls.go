@@ -0,0 +1,132 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var errNoClientCA = errors.New("privatetls: client CA pool must not be nil")
+
+// StartMTLSListener starts an HTTPS server like StartHTTPSListenerWithHandler
+// that requires every client to present a certificate signed by a CA in
+// clientCA, e.g. one issued by NewClientCertificate. Use VerifyClientCert to
+// check a client certificate against the pool before connecting.
+func StartMTLSListener(service string, clientCA *x509.CertPool, handler http.Handler) error {
+	s, err := newMTLSServer(service, clientCA, handler)
+	if err != nil {
+		return err
+	}
+
+	return s.ListenAndServeTLS("", "")
+}
+
+// NewClientCertificate Generates a TLS client certificate signed by the CA,
+// for use with a server started by StartMTLSListener. Unless set by the
+// options, the certificate carries no SANs; set the client identity with
+// WithCommonName.
+func NewClientCertificate(caKey crypto.Signer, caCert *x509.Certificate, opts ...Option) (tls.Certificate, error) {
+	if caCert == nil || caKey == nil {
+		return tls.Certificate{}, errNoParent
+	}
+
+	cfg := applyOptions(withoutSANs(defaultCertConfig()), opts)
+
+	cert, err := issueCert(cfg, setClientAttributes, caCert, caKey)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("privatetls: issuing client certificate: %w", err)
+	}
+
+	return cert, nil
+}
+
+// NewMTLSPair Generates a CA, and a server and a client certificate signed by
+// it, the minimum setup for mutual TLS: the server presents serverCert and
+// trusts caCert in its ClientCAs, while the client presents clientCert and
+// trusts caCert in its RootCAs. The server options apply to the server
+// certificate and, apart from the SANs, to the CA, like NewCAAndLeafCert. The
+// client options apply like they do to NewClientCertificate.
+func NewMTLSPair(serverOpts, clientOpts []Option) (serverCert, clientCert tls.Certificate, caCert *x509.Certificate, err error) {
+	ca, serverCert, err := NewCAAndLeafCert(serverOpts...)
+	if err != nil {
+		return
+	}
+
+	caCert, caKey, err := parseCertAndSigner(ca)
+	if err != nil {
+		return
+	}
+
+	clientCert, err = NewClientCertificate(caKey, caCert, clientOpts...)
+
+	return
+}
+
+// VerifyClientCert checks that the client certificate would be accepted by a
+// server started by StartMTLSListener with the client CA pool.
+func VerifyClientCert(cert tls.Certificate, clientCA *x509.CertPool) error {
+	if clientCA == nil {
+		return errNoClientCA
+	}
+
+	leaf, err := leafCertificate(cert)
+	if err != nil {
+		return err
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, der := range cert.Certificate[1:] {
+		c, err := x509.ParseCertificate(der)
+		if err != nil {
+			return err
+		}
+		intermediates.AddCert(c)
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         clientCA,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	if _, err := leaf.Verify(opts); err != nil {
+		return fmt.Errorf("privatetls: client certificate %q is not trusted by the client CA pool: %w",
+			leaf.Subject.CommonName, err)
+	}
+
+	return nil
+}
+
+// Create an HTTPS server that requires and verifies client certificates
+func newMTLSServer(service string, clientCA *x509.CertPool, handler http.Handler) (*http.Server, error) {
+	if clientCA == nil {
+		return nil, errNoClientCA
+	}
+
+	s, err := NewHTTPSServer(service)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Handler = handler
+	s.TLSConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	s.TLSConfig.ClientCAs = clientCA
+
+	return s, nil
+}
@@ -0,0 +1,132 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestStartMTLSListener(t *testing.T) {
+	caCert, _, err := NewCAAndLeafCert(WithEd25519())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	ca, caKey, err := parseCertAndSigner(caCert)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	clientCA := x509.NewCertPool()
+	clientCA.AddCert(ca)
+
+	clientCert, err := NewClientCertificate(caKey, ca, WithEd25519(), WithCommonName("client"))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if err := VerifyClientCert(clientCert, clientCA); err != nil {
+		t.Errorf("Unexpected error: %v\n", err)
+	}
+
+	untrustedCert, err := NewCert(WithEd25519())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if err := VerifyClientCert(untrustedCert, clientCA); err == nil {
+		t.Error("Expected an error for an untrusted client certificate")
+	}
+
+	addr := freeAddr(t)
+
+	go StartMTLSListener(addr, clientCA, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+
+	waitForListener(t, addr)
+
+	get := func(cert tls.Certificate) (string, error) {
+		client := http.Client{
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{
+				Certificates:       []tls.Certificate{cert},
+				InsecureSkipVerify: true,
+			}},
+		}
+
+		resp, err := client.Get("https://" + addr)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+
+		var body [64]byte
+		n, _ := resp.Body.Read(body[:])
+
+		return string(body[:n]), nil
+	}
+
+	if body, err := get(clientCert); err != nil || body != "client" {
+		t.Errorf("Expected the client to be accepted, got %q, %v\n", body, err)
+	}
+
+	if _, err := get(untrustedCert); err == nil {
+		t.Error("Expected an untrusted client to be rejected")
+	}
+}
+
+func TestStartMTLSListenerNilPool(t *testing.T) {
+	if err := StartMTLSListener("127.0.0.1:0", nil, nil); err == nil {
+		t.Error("Expected an error for a nil client CA pool")
+	}
+}
+
+func TestNewMTLSPair(t *testing.T) {
+	serverCert, clientCert, caCert, err := NewMTLSPair([]Option{WithEd25519()}, []Option{WithCommonName("worker")})
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(caCert)
+
+	serverConfig := &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	}
+
+	clientConfig := &tls.Config{
+		Certificates: []tls.Certificate{clientCert},
+		RootCAs:      pool,
+		ServerName:   "localhost",
+	}
+
+	if err := handshake(serverConfig, clientConfig); err != nil {
+		t.Errorf("Unexpected error: %v\n", err)
+	}
+
+	if clientCert.Leaf.Subject.CommonName != "worker" || len(clientCert.Leaf.DNSNames) != 0 {
+		t.Errorf("Unexpected client certificate subject or SANs: %v %v\n", clientCert.Leaf.Subject, clientCert.Leaf.DNSNames)
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto/x509"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Observer receives the lifecycle events of managed certificates, e.g. to
+// export metrics, see the metrics sub-package. The name identifies the
+// ManagedCert, see ManagedCert.Name. The methods are called synchronously
+// from the renewal goroutines, so they must not block.
+type Observer interface {
+	// CertGenerated reports a generated certificate and how long generating it took
+	CertGenerated(name string, notAfter time.Time, took time.Duration)
+	// CertRenewed reports that a renewed certificate replaced the previous one
+	CertRenewed(name string)
+	// CertClosed reports that the ManagedCert stopped its renewals
+	CertClosed(name string)
+}
+
+var (
+	observerMu sync.RWMutex
+	observer   Observer
+
+	// The names of the managed certificates still renewing
+	managedNamesMu sync.Mutex
+	managedNames   = make(map[string]bool)
+)
+
+// SetObserver installs the observer notified of the events of all managed
+// certificates, replacing the previous one. A nil observer disables the
+// notifications.
+func SetObserver(o Observer) {
+	observerMu.Lock()
+	defer observerMu.Unlock()
+
+	observer = o
+}
+
+// Return the installed observer, or nil
+func currentObserver() Observer {
+	observerMu.RLock()
+	defer observerMu.RUnlock()
+
+	return observer
+}
+
+// A name for the certificate in logs and metrics: the common name, or the
+// first SAN
+func certName(leaf *x509.Certificate) string {
+	switch {
+	case leaf.Subject.CommonName != "":
+		return leaf.Subject.CommonName
+	case len(leaf.DNSNames) > 0:
+		return leaf.DNSNames[0]
+	case len(leaf.IPAddresses) > 0:
+		return leaf.IPAddresses[0].String()
+	case len(leaf.URIs) > 0:
+		return leaf.URIs[0].String()
+	default:
+		return leaf.SerialNumber.String()
+	}
+}
+
+// Reserve a name for a managed certificate that no other one still renewing
+// uses, numbering it from 2 when the certificate name is taken, e.g. by
+// several certificates with the default SANs
+func reserveCertName(base string) string {
+	managedNamesMu.Lock()
+	defer managedNamesMu.Unlock()
+
+	name := base
+	for i := 2; managedNames[name]; i++ {
+		name = fmt.Sprintf("%s#%d", base, i)
+	}
+	managedNames[name] = true
+
+	return name
+}
+
+// Release the name of a managed certificate that stopped renewing
+func releaseCertName(name string) {
+	managedNamesMu.Lock()
+	defer managedNamesMu.Unlock()
+
+	delete(managedNames, name)
+}
@@ -0,0 +1,69 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto/tls"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingObserver struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (o *recordingObserver) record(event string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.events = append(o.events, event)
+}
+
+func (o *recordingObserver) CertGenerated(name string, _ time.Time, _ time.Duration) {
+	o.record("generated " + name)
+}
+
+func (o *recordingObserver) CertRenewed(name string) { o.record("renewed " + name) }
+
+func (o *recordingObserver) CertClosed(name string) { o.record("closed " + name) }
+
+func TestObserver(t *testing.T) {
+	o := &recordingObserver{}
+	SetObserver(o)
+	defer SetObserver(nil)
+
+	m, err := AutoRenew(time.Minute, func() (tls.Certificate, error) {
+		return NewCert(WithEd25519(), WithCommonName("observed"))
+	})
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if m.Name() != "observed" {
+		t.Errorf("Unexpected name %q\n", m.Name())
+	}
+
+	m.Close()
+	m.Close()
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if len(o.events) != 2 || o.events[0] != "generated observed" || o.events[1] != "closed observed" {
+		t.Errorf("Unexpected events: %v\n", o.events)
+	}
+}
@@ -0,0 +1,385 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+const (
+	ocspResponseLifetime = time.Hour // Lifetime of locally signed responses, and of responses without NextUpdate
+	ocspMaxResponseSize  = 1 << 20
+	ocspRequestTimeout   = 10 * time.Second
+	ocspRetryInterval    = time.Minute    // Delay before fetching again after a failure
+	ocspIdleTimeout      = 24 * time.Hour // Cached responses of certificates not served for that long are evicted
+)
+
+// WithOCSPStapling staples an OCSP response for the issued certificate to
+// every handshake. The responses are fetched from the OCSP responder at
+// responderURL and verified against caCert, or, if responderURL is empty,
+// signed locally with caKey, which needs no responder at all. Local responses
+// report the revocation status from the CA's CRL, fetched from the CRL
+// distribution points of the certificate, e.g. set with
+// WithCRLDistributionPoints and published with NewCRL; certificates without a
+// verifiable CRL get no staple. OCSP responses cannot be signed with Ed25519
+// keys, so the CA must use an RSA or ECDSA key.
+//
+// The responses are cached per certificate and refreshed in the background
+// halfway through their validity, so handshakes never wait for the
+// responder. A failed refresh is retried a minute later. Until the first
+// response arrives, and after the cached one expires without a successful
+// refresh, handshakes proceed without a staple. The responses of the
+// certificates set before the option are fetched right away, those of the
+// certificates returned by GetCertificate are evicted once they have not been
+// served for a day. Refreshes stop when the configuration, and every clone of
+// it, is no longer referenced.
+//
+// The option wraps the certificates set by options applied before it, e.g.
+// WithCertificates, so it must come after them.
+func WithOCSPStapling(responderURL string, caCert *x509.Certificate, caKey crypto.Signer) TLSOption {
+	return func(c *tls.Config) error {
+		if caCert == nil {
+			return errors.New("privatetls: OCSP stapling requires the CA certificate")
+		}
+
+		if responderURL == "" && caKey == nil {
+			return errors.New("privatetls: OCSP stapling without a responder requires the CA key")
+		}
+
+		if len(c.Certificates) == 0 && c.GetCertificate == nil {
+			return errors.New("privatetls: OCSP stapling requires certificates to be set first")
+		}
+
+		s := newOCSPStapler(responderURL, caCert, caKey)
+
+		// crypto/tls prefers Certificates over GetCertificate without SNI
+		certs, getCertificate := c.Certificates, c.GetCertificate
+		for i := range certs {
+			s.staple(&certs[i], true)
+		}
+
+		// The handle is only referenced by the configuration, so its cleanup
+		// stops the refreshes once the configuration is gone
+		h := &ocspHandle{s}
+		runtime.AddCleanup(h, (*ocspStapler).stop, s)
+
+		c.Certificates = nil
+		c.GetCertificate = func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			cert, err := selectCertificate(hello, certs, getCertificate)
+			if err != nil || cert == nil {
+				return cert, err
+			}
+
+			stapled := *cert
+			stapled.OCSPStaple = h.s.staple(cert, false)
+			return &stapled, nil
+		}
+		return nil
+	}
+}
+
+// Select the certificate for the client like crypto/tls does, preferring the
+// getter over the first certificate supported by the client
+func selectCertificate(hello *tls.ClientHelloInfo, certs []tls.Certificate,
+	getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)) (*tls.Certificate, error) {
+	if getCertificate != nil {
+		if cert, err := getCertificate(hello); cert != nil || err != nil {
+			return cert, err
+		}
+	}
+
+	if len(certs) == 0 {
+		return nil, errors.New("privatetls: no certificates configured")
+	}
+
+	for i := range certs {
+		if hello.SupportsCertificate(&certs[i]) == nil {
+			return &certs[i], nil
+		}
+	}
+
+	return &certs[0], nil
+}
+
+// Caches the OCSP responses stapled to the certificates issued by a CA
+type ocspStapler struct {
+	responderURL string
+	caCert       *x509.Certificate
+	caKey        crypto.Signer
+	client       *http.Client
+	fetch        func(ctx context.Context, leaf *x509.Certificate) (*ocsp.Response, error)
+
+	ctx    context.Context // Cancelled to stop the refreshes
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	entries map[string]*ocspEntry // By serial number
+	sweepAt time.Time
+}
+
+// The cached OCSP response of a certificate
+type ocspEntry struct {
+	resp       *ocsp.Response
+	static     bool // Set before the option, so never evicted
+	served     time.Time
+	refreshing bool
+	retryAt    time.Time
+}
+
+// Referenced by the wrapped configuration only
+type ocspHandle struct {
+	s *ocspStapler
+}
+
+func newOCSPStapler(responderURL string, caCert *x509.Certificate, caKey crypto.Signer) *ocspStapler {
+	s := &ocspStapler{
+		responderURL: responderURL,
+		caCert:       caCert,
+		caKey:        caKey,
+		client:       &http.Client{Timeout: ocspRequestTimeout},
+		entries:      make(map[string]*ocspEntry),
+	}
+
+	s.fetch = s.fetchResponse
+	if responderURL == "" {
+		s.fetch = s.signResponse
+	}
+
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	return s
+}
+
+// Cancel the refreshes in flight and don't start new ones
+func (s *ocspStapler) stop() {
+	s.cancel()
+}
+
+// Return the cached OCSP response for the certificate while it is valid, or
+// nil, starting a refresh in the background when one is due
+func (s *ocspStapler) staple(cert *tls.Certificate, static bool) []byte {
+	leaf, err := leafCertificate(*cert)
+	if err != nil {
+		return nil
+	}
+
+	key := leaf.SerialNumber.String()
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.evict(now)
+
+	e := s.entries[key]
+	if e == nil {
+		e = &ocspEntry{static: static}
+		s.entries[key] = e
+	}
+	e.served = now
+
+	due := e.resp == nil || !now.Before(ocspRefreshTime(e.resp))
+	if due && !e.refreshing && !now.Before(e.retryAt) && s.ctx.Err() == nil {
+		e.refreshing = true
+		go s.refresh(e, leaf)
+	}
+
+	if e.resp != nil && now.Before(ocspExpiryTime(e.resp)) {
+		return e.resp.Raw
+	}
+
+	return nil
+}
+
+// Drop the entries of the certificates that are no longer served, at most
+// once per idle timeout
+func (s *ocspStapler) evict(now time.Time) {
+	if now.Before(s.sweepAt) {
+		return
+	}
+	s.sweepAt = now.Add(ocspIdleTimeout)
+
+	for key, e := range s.entries {
+		if !e.static && !e.refreshing && now.Sub(e.served) >= ocspIdleTimeout {
+			delete(s.entries, key)
+		}
+	}
+}
+
+// Replace the cached response, keeping the previous one and retrying later
+// if the fetch fails
+func (s *ocspStapler) refresh(e *ocspEntry, leaf *x509.Certificate) {
+	resp, err := s.fetch(s.ctx, leaf)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err == nil {
+		e.resp = resp
+		e.retryAt = time.Time{}
+	} else {
+		e.retryAt = time.Now().Add(ocspRetryInterval)
+	}
+	e.refreshing = false
+}
+
+// Sign a response with the CA key, for the revocation status of the
+// certificate in the CA's CRL
+func (s *ocspStapler) signResponse(ctx context.Context, leaf *x509.Certificate) (*ocsp.Response, error) {
+	crl, err := s.fetchCRL(ctx, leaf)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	template := ocsp.Response{
+		Status:       ocsp.Good,
+		SerialNumber: leaf.SerialNumber,
+		ThisUpdate:   now,
+		NextUpdate:   now.Add(ocspResponseLifetime),
+	}
+
+	// The response is not valid for longer than the status it reports
+	if !crl.NextUpdate.IsZero() && crl.NextUpdate.Before(template.NextUpdate) {
+		template.NextUpdate = crl.NextUpdate
+	}
+
+	for _, entry := range crl.RevokedCertificateEntries {
+		if entry.SerialNumber.Cmp(leaf.SerialNumber) == 0 {
+			template.Status = ocsp.Revoked
+			template.RevokedAt = entry.RevocationTime
+			break
+		}
+	}
+
+	raw, err := ocsp.CreateResponse(s.caCert, s.caCert, template, s.caKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return ocsp.ParseResponse(raw, s.caCert)
+}
+
+// Fetch the current CRL signed by the CA from the first distribution point of
+// the certificate that serves one
+func (s *ocspStapler) fetchCRL(ctx context.Context, leaf *x509.Certificate) (*x509.RevocationList, error) {
+	if len(leaf.CRLDistributionPoints) == 0 {
+		return nil, errors.New("privatetls: certificate has no CRL distribution point")
+	}
+
+	var errs []error
+	for _, url := range leaf.CRLDistributionPoints {
+		crl, err := s.fetchCRLFrom(ctx, url)
+		if err == nil {
+			return crl, nil
+		}
+		errs = append(errs, err)
+	}
+
+	return nil, errors.Join(errs...)
+}
+
+func (s *ocspStapler) fetchCRLFrom(ctx context.Context, url string) (*x509.RevocationList, error) {
+	raw, err := s.send(ctx, http.MethodGet, url, "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// NewCRL publishes PEM, other CAs often DER
+	if block, _ := pem.Decode(raw); block != nil && block.Type == "X509 CRL" {
+		raw = block.Bytes
+	}
+
+	crl, err := x509.ParseRevocationList(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := crl.CheckSignatureFrom(s.caCert); err != nil {
+		return nil, fmt.Errorf("privatetls: CRL at %s is not signed by the CA: %w", url, err)
+	}
+
+	if !crl.NextUpdate.IsZero() && time.Now().After(crl.NextUpdate) {
+		return nil, fmt.Errorf("privatetls: CRL at %s has expired", url)
+	}
+
+	return crl, nil
+}
+
+// Fetch a fresh OCSP response for the certificate from the responder
+func (s *ocspStapler) fetchResponse(ctx context.Context, leaf *x509.Certificate) (*ocsp.Response, error) {
+	req, err := ocsp.CreateRequest(leaf, s.caCert, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := s.send(ctx, http.MethodPost, s.responderURL, "application/ocsp-request", req)
+	if err != nil {
+		return nil, err
+	}
+
+	return ocsp.ParseResponseForCert(raw, leaf, s.caCert)
+}
+
+// Send the request and read the response body, up to the maximum response size
+func (s *ocspStapler) send(ctx context.Context, method, url, contentType string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	httpResp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("privatetls: %s returned %s", url, httpResp.Status)
+	}
+
+	return io.ReadAll(io.LimitReader(httpResp.Body, ocspMaxResponseSize))
+}
+
+// The time after which the response should be refreshed: halfway through its
+// validity
+func ocspRefreshTime(resp *ocsp.Response) time.Time {
+	return resp.ThisUpdate.Add(ocspExpiryTime(resp).Sub(resp.ThisUpdate) / 2)
+}
+
+// The time after which the response must no longer be stapled
+func ocspExpiryTime(resp *ocsp.Response) time.Time {
+	if resp.NextUpdate.IsZero() {
+		return resp.ThisUpdate.Add(ocspResponseLifetime)
+	}
+
+	return resp.NextUpdate
+}
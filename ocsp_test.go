@@ -0,0 +1,272 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"context"
+	"crypto"
+	"crypto/elliptic"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+func TestWithOCSPStaplingLocal(t *testing.T) {
+	var crl atomic.Value
+	crlServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(crl.Load().([]byte))
+	}))
+	defer crlServer.Close()
+
+	caCert, leafCert, err := NewCAAndLeafCert(WithECDSACurve(elliptic.P256()), WithCRLDistributionPoints(crlServer.URL))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	ca := mustParseCert(t, caCert)
+	caKey := caCert.PrivateKey.(crypto.Signer)
+	leaf := mustParseCert(t, leafCert)
+
+	for _, revoked := range []bool{false, true} {
+		var entries []pkix.RevokedCertificate
+		if revoked {
+			entries = append(entries, pkix.RevokedCertificate{SerialNumber: leaf.SerialNumber, RevocationTime: time.Now().Add(-time.Hour)})
+		}
+
+		pemCRL, err := NewCRL(caKey, ca, entries, time.Now().Add(time.Hour))
+
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+
+		crl.Store(pemCRL)
+
+		cfg, err := NewTLSConfig(WithCertificates(leafCert), WithOCSPStapling("", ca, caKey))
+
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+
+		want := ocsp.Good
+		if revoked {
+			want = ocsp.Revoked
+		}
+
+		if resp := stapledResponse(t, cfg, ca); resp.Status != want {
+			t.Errorf("Expected OCSP status %d, got %d\n", want, resp.Status)
+		}
+	}
+
+	// No staple while the revocation status is unknown
+	_, noCRLCert, err := NewCAAndLeafCert(WithECDSACurve(elliptic.P256()))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	cfg, err := NewTLSConfig(WithCertificates(noCRLCert), WithOCSPStapling("", ca, caKey))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	if raw := handshakeStaple(t, cfg); raw != nil {
+		t.Error("Expected no OCSP staple without a CRL")
+	}
+}
+
+func TestWithOCSPStaplingResponder(t *testing.T) {
+	caCert, leafCert, err := NewCAAndLeafCert(WithECDSACurve(elliptic.P256()))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	ca := mustParseCert(t, caCert)
+
+	var requests int32
+	responder := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+
+		body, _ := io.ReadAll(r.Body)
+		req, err := ocsp.ParseRequest(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		resp, _ := ocsp.CreateResponse(ca, ca, ocsp.Response{
+			Status:       ocsp.Revoked,
+			SerialNumber: req.SerialNumber,
+			ThisUpdate:   time.Now(),
+			NextUpdate:   time.Now().Add(time.Hour),
+			RevokedAt:    time.Now(),
+		}, caCert.PrivateKey.(crypto.Signer))
+		w.Write(resp)
+	}))
+	defer responder.Close()
+
+	cfg, err := NewTLSConfig(WithCertificates(leafCert), WithOCSPStapling(responder.URL, ca, nil))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if resp := stapledResponse(t, cfg, ca); resp.Status != ocsp.Revoked {
+			t.Errorf("Expected the responder status to be stapled, got %d\n", resp.Status)
+		}
+	}
+
+	// Handshakes share the fetch in flight, later ones use the cached response
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("Expected 1 OCSP request, got %d\n", n)
+	}
+}
+
+func TestWithOCSPStaplingInvalid(t *testing.T) {
+	cert, err := NewCertEd25519()
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if _, err := NewTLSConfig(WithCertificates(cert), WithOCSPStapling("", cert.Leaf, nil)); err == nil {
+		t.Error("Expected an error without a responder or CA key")
+	}
+
+	if _, err := NewTLSConfig(WithCertificates(cert), WithOCSPStapling("http://ocsp.internal", nil, nil)); err == nil {
+		t.Error("Expected an error without a CA certificate")
+	}
+
+	if _, err := NewTLSConfig(WithOCSPStapling("http://ocsp.internal", cert.Leaf, nil)); err == nil {
+		t.Error("Expected an error without certificates")
+	}
+}
+
+func TestOCSPStaplerEvictAndStop(t *testing.T) {
+	var fetches int32
+	s := newOCSPStapler("http://ocsp.internal", nil, nil)
+	s.fetch = func(context.Context, *x509.Certificate) (*ocsp.Response, error) {
+		atomic.AddInt32(&fetches, 1)
+		return nil, errors.New("unavailable")
+	}
+
+	certs := make([]tls.Certificate, 3)
+	for i := range certs {
+		cert, err := NewCertEd25519()
+
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+
+		certs[i] = cert
+	}
+
+	s.staple(&certs[0], true)
+	s.staple(&certs[1], false)
+
+	// Wait for the failed fetches, then pretend a day has passed
+	for atomic.LoadInt32(&fetches) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	s.mu.Lock()
+	for _, e := range s.entries {
+		for e.refreshing {
+			s.mu.Unlock()
+			time.Sleep(time.Millisecond)
+			s.mu.Lock()
+		}
+		e.served = e.served.Add(-ocspIdleTimeout)
+	}
+	s.sweepAt = time.Time{}
+	s.mu.Unlock()
+
+	s.stop()
+	s.staple(&certs[2], false)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.entries[certs[0].Leaf.SerialNumber.String()]; !ok {
+		t.Error("Expected the static certificate to stay cached")
+	}
+
+	if _, ok := s.entries[certs[1].Leaf.SerialNumber.String()]; ok {
+		t.Error("Expected the certificate no longer served to be evicted")
+	}
+
+	if e := s.entries[certs[2].Leaf.SerialNumber.String()]; e == nil || e.refreshing {
+		t.Error("Expected no refresh after the stapler is stopped")
+	}
+}
+
+// Perform handshakes with the server configuration until a response is
+// stapled, since they are fetched in the background, and parse it
+func stapledResponse(t *testing.T, cfg *tls.Config, caCert *x509.Certificate) *ocsp.Response {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	raw := handshakeStaple(t, cfg)
+	for raw == nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		raw = handshakeStaple(t, cfg)
+	}
+
+	if raw == nil {
+		t.Fatal("Expected a stapled OCSP response")
+	}
+
+	resp, err := ocsp.ParseResponse(raw, caCert)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	return resp
+}
+
+// Perform a handshake with the server configuration, returning the stapled OCSP response
+func handshakeStaple(t *testing.T, cfg *tls.Config) []byte {
+	t.Helper()
+
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+
+	go func() {
+		defer serverConn.Close()
+		tls.Server(serverConn, cfg).Handshake()
+	}()
+
+	client := tls.Client(clientConn, &tls.Config{InsecureSkipVerify: true})
+	if err := client.Handshake(); err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	return client.ConnectionState().OCSPResponse
+}
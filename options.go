@@ -0,0 +1,620 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// KeyType identifies the algorithm used for the generated private key
+type KeyType int
+
+const (
+	// KeyTypeRSA generates an RSA key. This is the default.
+	KeyTypeRSA KeyType = iota
+	// KeyTypeECDSA generates an ECDSA key
+	KeyTypeECDSA
+	// KeyTypeEd25519 generates an Ed25519 key
+	KeyTypeEd25519
+)
+
+// CertOptions describes the certificate generated by NewCertWithOptions.
+// Zero valued fields fall back to the defaults used by NewCert.
+type CertOptions struct {
+	// KeyType selects the key algorithm, RSA by default
+	KeyType KeyType
+
+	// KeyBits is the RSA modulus length (2048 by default), or the ECDSA
+	// curve size: 256 (default), 384 or 521. It is ignored for Ed25519.
+	KeyBits int
+
+	// ValidFor is the certificate validity period, 1 year by default
+	ValidFor time.Duration
+
+	// IPAddresses are the IP SANs, 127.0.0.1 and ::1 by default
+	IPAddresses []net.IP
+
+	// DNSNames are the DNS SANs, localhost by default
+	DNSNames []string
+
+	// Organization is the subject organization, "PrivateTLS" by default
+	Organization []string
+
+	// CommonName is the subject common name, blank by default
+	CommonName string
+
+	// AuditLog receives an entry for the certificate, see WithAuditLog
+	AuditLog io.Writer
+}
+
+// Option changes the certificate generated by NewCert
+type Option func(*certConfig)
+
+// WithRSAKeyBits generates an RSA key with the given modulus length. The
+// default is 2048 bits. Lengths below 512 bits are rejected; note that recent
+// Go releases also refuse to generate keys below 1024 bits.
+func WithRSAKeyBits(bits int) Option {
+	return func(c *certConfig) {
+		c.keyType = KeyTypeRSA
+		c.rsaBits = bits
+	}
+}
+
+// WithECDSACurve generates an ECDSA key on the given curve
+func WithECDSACurve(curve elliptic.Curve) Option {
+	return func(c *certConfig) {
+		c.keyType = KeyTypeECDSA
+		c.curve = curve
+	}
+}
+
+// WithEd25519 generates an Ed25519 key
+func WithEd25519() Option {
+	return func(c *certConfig) {
+		c.keyType = KeyTypeEd25519
+	}
+}
+
+// WithValidity sets the period the certificate is valid for
+func WithValidity(d time.Duration) Option {
+	return func(c *certConfig) {
+		c.validFor = d
+	}
+}
+
+// WithNotBefore sets the start of the validity period, which is the time of
+// generation by default. Back-dated certificates are useful in tests.
+func WithNotBefore(t time.Time) Option {
+	return func(c *certConfig) {
+		c.notBefore = t
+	}
+}
+
+// WithNotBeforeOffset moves the start of the validity period offset into the
+// past, so that clients with clocks running behind do not reject a freshly
+// generated certificate as not yet valid. The whole validity period moves,
+// so the certificate expires offset earlier than it would otherwise, and
+// short-lived certificates should use a small offset. The default is one
+// minute; pass 0 for certificates that must not be valid before their
+// generation, e.g. for strict audit trails. The offset does not apply when
+// WithNotBefore is set.
+func WithNotBeforeOffset(offset time.Duration) Option {
+	return func(c *certConfig) {
+		c.notBeforeOffset = offset
+	}
+}
+
+// WithAutoKeyIDs sets the subject key identifier of the certificate to the
+// SHA-1 hash of its public key, per method 1 of RFC 5280, and the authority
+// key identifier to that of the issuer, i.e. to the same value for
+// self-signed certificates. Many enterprise PKI validators require them, and
+// they help match certificates to their issuers when debugging chains.
+func WithAutoKeyIDs() Option {
+	return func(c *certConfig) {
+		c.autoKeyIDs = true
+	}
+}
+
+// WithIPAddresses replaces the default IP SANs of the certificate
+func WithIPAddresses(ips ...net.IP) Option {
+	return func(c *certConfig) {
+		c.ipAddresses = ips
+	}
+}
+
+// WithIPv6Loopback adds ::1 to the IP SANs of the certificate, unless it is
+// already present
+func WithIPv6Loopback() Option {
+	return func(c *certConfig) {
+		c.ipAddresses = appendIP(c.ipAddresses, net.IPv6loopback)
+	}
+}
+
+// WithDNSNames replaces the default DNS SANs of the certificate. A wildcard
+// name such as *.example.internal covers the direct subdomains; bare and
+// nested wildcards are rejected when the certificate is generated.
+func WithDNSNames(names ...string) Option {
+	return func(c *certConfig) {
+		c.dnsNames = names
+	}
+}
+
+// WithURISANs sets the URI SANs of the certificate, e.g. a SPIFFE ID such
+// as spiffe://cluster.local/ns/default/sa/myservice
+func WithURISANs(uris ...*url.URL) Option {
+	return func(c *certConfig) {
+		c.uris = uris
+	}
+}
+
+// WithEmailAddresses sets the email (RFC 822) SANs of the certificate.
+// Certificate generation fails if an address is obviously malformed.
+func WithEmailAddresses(addrs ...string) Option {
+	return func(c *certConfig) {
+		c.emailAddresses = addrs
+	}
+}
+
+// WithOrganization replaces the default subject organization
+func WithOrganization(org ...string) Option {
+	return func(c *certConfig) {
+		c.organization = org
+	}
+}
+
+// WithOrganizationalUnit sets the subject organizational units, e.g. "Engineering"
+func WithOrganizationalUnit(ous ...string) Option {
+	return func(c *certConfig) {
+		c.organizationalUnit = ous
+	}
+}
+
+// WithLocality sets the subject localities, i.e. cities
+func WithLocality(localities ...string) Option {
+	return func(c *certConfig) {
+		c.locality = localities
+	}
+}
+
+// WithProvince sets the subject provinces or states
+func WithProvince(provinces ...string) Option {
+	return func(c *certConfig) {
+		c.province = provinces
+	}
+}
+
+// WithCountry sets the subject countries, as two-letter ISO 3166 codes, e.g. "US"
+func WithCountry(countries ...string) Option {
+	return func(c *certConfig) {
+		c.country = countries
+	}
+}
+
+// WithPostalCode sets the subject postal codes
+func WithPostalCode(codes ...string) Option {
+	return func(c *certConfig) {
+		c.postalCode = codes
+	}
+}
+
+// WithCommonName sets the subject common name
+func WithCommonName(cn string) Option {
+	return func(c *certConfig) {
+		c.commonName = cn
+	}
+}
+
+// WithCommonNameFromAddr sets the subject common name to the host of a listen
+// or dial address, e.g. "myservice.internal" for "myservice.internal:8443" or
+// "::1" for "[::1]:443". An empty host, or one on all interfaces such as
+// "0.0.0.0" or "::", falls back to "localhost".
+func WithCommonNameFromAddr(addr string) Option {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+	}
+
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "localhost"
+	}
+
+	return WithCommonName(host)
+}
+
+// WithPKCS8Key encodes the private key as a PKCS8 "PRIVATE KEY" PEM block,
+// as required by Java and some HSMs, instead of the key type specific
+// "RSA PRIVATE KEY" or "EC PRIVATE KEY" blocks. Ed25519 keys always use PKCS8.
+func WithPKCS8Key() Option {
+	return func(c *certConfig) {
+		c.pkcs8Key = true
+	}
+}
+
+// WithEncryptedKey encrypts the private key returned by NewCertPEM and written
+// by WriteCertFiles with the password, using AES-256. Load it back with
+// LoadEncryptedKey. The option does not affect NewCert, since a tls.Certificate
+// holds the decrypted key.
+func WithEncryptedKey(password []byte) Option {
+	return func(c *certConfig) {
+		c.keyPassword = password
+	}
+}
+
+// WithCRLDistributionPoints embeds the URLs where relying parties can fetch
+// the CRL of the issuing CA, e.g. one published with NewCRL
+func WithCRLDistributionPoints(urls ...string) Option {
+	return func(c *certConfig) {
+		c.crlDistributionPoints = urls
+	}
+}
+
+// WithOCSPServer embeds the URL of the OCSP responder that relying parties
+// query for the revocation status of the certificate. This package only
+// handles the certificate side: callers are responsible for running a
+// compatible OCSP responder at that URL.
+func WithOCSPServer(url string) Option {
+	return func(c *certConfig) {
+		c.ocspServers = append(c.ocspServers, url)
+	}
+}
+
+// WithPermittedDNSDomains limits the DNS names a CA certificate can sign to
+// the domains and their subdomains. Like the other name constraints options
+// it only affects CA certificates, e.g. those created by NewCAAndLeafCert and
+// NewIntermediateCA.
+func WithPermittedDNSDomains(domains ...string) Option {
+	return func(c *certConfig) {
+		c.permittedDNSDomains = domains
+	}
+}
+
+// WithExcludedDNSDomains forbids a CA certificate to sign the DNS domains and
+// their subdomains
+func WithExcludedDNSDomains(domains ...string) Option {
+	return func(c *certConfig) {
+		c.excludedDNSDomains = domains
+	}
+}
+
+// WithPermittedIPRanges limits the IP addresses a CA certificate can sign to
+// the networks
+func WithPermittedIPRanges(nets ...*net.IPNet) Option {
+	return func(c *certConfig) {
+		c.permittedIPRanges = nets
+	}
+}
+
+// WithExcludedIPRanges forbids a CA certificate to sign addresses in the networks
+func WithExcludedIPRanges(nets ...*net.IPNet) Option {
+	return func(c *certConfig) {
+		c.excludedIPRanges = nets
+	}
+}
+
+// WithKeyUsage replaces the key usage chosen for the kind of certificate,
+// e.g. x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment
+func WithKeyUsage(usage x509.KeyUsage) Option {
+	return func(c *certConfig) {
+		c.keyUsage = usage
+	}
+}
+
+// WithExtKeyUsage replaces the extended key usages chosen for the kind of
+// certificate, by default server and client authentication. Passing no usages
+// omits the extension.
+func WithExtKeyUsage(usages ...x509.ExtKeyUsage) Option {
+	return func(c *certConfig) {
+		c.extKeyUsage = append([]x509.ExtKeyUsage{}, usages...)
+	}
+}
+
+// ServerAuthProfile returns the options for a TLS server certificate: digital
+// signatures and key encipherment, for server authentication only. Combine it
+// with other options, e.g. NewCert(append(ServerAuthProfile(), WithDNSNames("svc.internal"))...).
+// Like the other profiles, it makes NewCert generate a self-signed leaf
+// rather than a CA, which cannot sign certificates.
+func ServerAuthProfile() []Option {
+	return []Option{
+		WithKeyUsage(x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment),
+		WithExtKeyUsage(x509.ExtKeyUsageServerAuth),
+		asLeaf(),
+	}
+}
+
+// ClientAuthProfile returns the options for a TLS client certificate
+func ClientAuthProfile() []Option {
+	return []Option{
+		WithKeyUsage(x509.KeyUsageDigitalSignature),
+		WithExtKeyUsage(x509.ExtKeyUsageClientAuth),
+		asLeaf(),
+	}
+}
+
+// CodeSigningProfile returns the options for a code signing certificate,
+// which is not valid for TLS
+func CodeSigningProfile() []Option {
+	return []Option{
+		WithKeyUsage(x509.KeyUsageDigitalSignature),
+		WithExtKeyUsage(x509.ExtKeyUsageCodeSigning),
+		asLeaf(),
+	}
+}
+
+// Generate a self-signed leaf instead of a self-signed CA, for the profiles
+// whose key usages do not allow signing certificates
+func asLeaf() Option {
+	return func(c *certConfig) {
+		c.selfSignedLeaf = true
+	}
+}
+
+// WithRandReader replaces crypto/rand.Reader as the source of randomness for
+// the serial number, Ed25519 keys and signatures, e.g. with a reader that
+// fails to exercise error paths in tests. Since Go 1.26 crypto/rsa and
+// crypto/ecdsa ignore custom readers outside of testing/cryptotest, so RSA
+// and ECDSA keys stay random. Never use a predictable reader in production.
+func WithRandReader(r io.Reader) Option {
+	return func(c *certConfig) {
+		c.random = r
+	}
+}
+
+// The resolved description of a certificate to generate
+type certConfig struct {
+	keyType        KeyType
+	rsaBits        int
+	curve          elliptic.Curve
+	validFor       time.Duration
+	notBefore      time.Time
+	ipAddresses    []net.IP
+	dnsNames       []string
+	uris           []*url.URL
+	emailAddresses []string
+	organization   []string
+	commonName     string
+	pkcs8Key       bool
+	keyPassword    []byte
+
+	organizationalUnit []string
+	locality           []string
+	province           []string
+	country            []string
+	postalCode         []string
+
+	notBeforeOffset       time.Duration
+	crlDistributionPoints []string
+	ocspServers           []string
+	scts                  [][]byte
+	ctLogs                []string
+	extraExtensions       []pkix.Extension
+	policyIdentifiers     []asn1.ObjectIdentifier
+	keyUsage              x509.KeyUsage
+	autoKeyIDs            bool
+	selfSignedLeaf        bool
+	extKeyUsage           []x509.ExtKeyUsage
+	serials               SerialRegistry
+	random                io.Reader
+	auditLog              io.Writer
+
+	permittedDNSDomains []string
+	excludedDNSDomains  []string
+	permittedIPRanges   []*net.IPNet
+	excludedIPRanges    []*net.IPNet
+}
+
+// The configuration used by NewCert
+func defaultCertConfig() *certConfig {
+	return &certConfig{
+		keyType:         KeyTypeRSA,
+		rsaBits:         rsaKeyLength,
+		curve:           elliptic.P256(),
+		validFor:        defaultValidity,
+		notBeforeOffset: notBeforeGrace,
+		ipAddresses:     []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("::1")},
+		dnsNames:        []string{"localhost"},
+		organization:    []string{"PrivateTLS"},
+	}
+}
+
+// The subject name of the certificate
+func (c *certConfig) subject() pkix.Name {
+	return pkix.Name{
+		Country:            c.country,
+		Organization:       c.organization,
+		OrganizationalUnit: c.organizationalUnit,
+		Locality:           c.locality,
+		Province:           c.province,
+		PostalCode:         c.postalCode,
+		CommonName:         c.commonName,
+	}
+}
+
+// Append an IP address unless an equal one is already present. net.IP.Equal
+// treats the 4 and 16 byte forms of an IPv4 address as the same address.
+func appendIP(ips []net.IP, ip net.IP) []net.IP {
+	for _, existing := range ips {
+		if existing.Equal(ip) {
+			return ips
+		}
+	}
+
+	// Never append into the caller's backing array
+	return append(ips[:len(ips):len(ips)], ip)
+}
+
+// Apply the options on top of the defaults
+func newCertConfig(opts []Option) *certConfig {
+	return applyOptions(defaultCertConfig(), opts)
+}
+
+// The source of randomness for keys, serial numbers and signatures
+func (c *certConfig) randReader() io.Reader {
+	if c.random == nil {
+		return rand.Reader
+	}
+
+	return c.random
+}
+
+// Apply the options on top of the supplied configuration
+func applyOptions(cfg *certConfig, opts []Option) *certConfig {
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return cfg
+}
+
+var errNilCurve = errors.New("privatetls: elliptic curve must not be nil")
+
+// Reject configurations that cannot produce a usable certificate
+func (c *certConfig) validate() error {
+	if c.keyType == KeyTypeECDSA && c.curve == nil {
+		return errNilCurve
+	}
+
+	if c.keyType == KeyTypeRSA && c.rsaBits < minRSAKeyLength {
+		return fmt.Errorf("privatetls: RSA key length %d is below the minimum of %d bits", c.rsaBits, minRSAKeyLength)
+	}
+
+	if c.keyPassword != nil && len(c.keyPassword) == 0 {
+		return errors.New("privatetls: key password must not be empty")
+	}
+
+	if c.validFor <= 0 {
+		return fmt.Errorf("privatetls: invalid validity period %v", c.validFor)
+	}
+
+	for _, country := range c.country {
+		if len(country) != 2 || !isUpperASCII(country) {
+			return fmt.Errorf("privatetls: country %q is not a two-letter ISO 3166 code", country)
+		}
+	}
+
+	if c.notBeforeOffset < 0 {
+		return fmt.Errorf("privatetls: invalid not before offset %v", c.notBeforeOffset)
+	}
+
+	for _, name := range c.dnsNames {
+		if err := checkWildcard(name); err != nil {
+			return err
+		}
+	}
+
+	for _, u := range c.uris {
+		if u == nil {
+			return errors.New("privatetls: URI SAN must not be nil")
+		}
+	}
+
+	for _, addr := range c.emailAddresses {
+		if !isEmailAddress(addr) {
+			return fmt.Errorf("privatetls: malformed email address %q", addr)
+		}
+	}
+
+	return nil
+}
+
+// Report whether s only consists of the letters A to Z
+func isUpperASCII(s string) bool {
+	for _, r := range s {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Reject the wildcard patterns TLS clients do not accept: a wildcard must be
+// the whole leftmost label, followed by a domain, e.g. *.example.internal
+func checkWildcard(name string) error {
+	if !strings.Contains(name, "*") {
+		return nil
+	}
+
+	rest := strings.TrimPrefix(name, "*.")
+	if rest == name || rest == "" || strings.Contains(rest, "*") {
+		return fmt.Errorf("privatetls: unsupported wildcard DNS name %q", name)
+	}
+
+	return nil
+}
+
+// A syntactic sanity check: a non-empty local part and domain separated by a
+// single @, and no whitespace
+func isEmailAddress(addr string) bool {
+	at := strings.IndexByte(addr, '@')
+	if at <= 0 || at == len(addr)-1 || strings.Count(addr, "@") != 1 {
+		return false
+	}
+
+	return !strings.ContainsAny(addr, " \t\r\n")
+}
+
+// Resolve the options into a configuration, applying the defaults
+func (o CertOptions) config() (*certConfig, error) {
+	cfg := defaultCertConfig()
+
+	switch o.KeyType {
+	case KeyTypeRSA:
+		if o.KeyBits != 0 {
+			cfg.rsaBits = o.KeyBits
+		}
+	case KeyTypeECDSA:
+		switch o.KeyBits {
+		case 0, 256:
+			cfg.curve = elliptic.P256()
+		case 384:
+			cfg.curve = elliptic.P384()
+		case 521:
+			cfg.curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("privatetls: unsupported ECDSA key size %d", o.KeyBits)
+		}
+	case KeyTypeEd25519:
+	default:
+		return nil, fmt.Errorf("privatetls: unsupported key type %d", o.KeyType)
+	}
+	cfg.keyType = o.KeyType
+
+	if o.ValidFor != 0 {
+		cfg.validFor = o.ValidFor
+	}
+	if o.IPAddresses != nil {
+		cfg.ipAddresses = o.IPAddresses
+	}
+	if o.DNSNames != nil {
+		cfg.dnsNames = o.DNSNames
+	}
+	if o.Organization != nil {
+		cfg.organization = o.Organization
+	}
+	cfg.commonName = o.CommonName
+	cfg.auditLog = o.AuditLog
+
+	return cfg, nil
+}
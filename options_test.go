@@ -0,0 +1,526 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestNewCertWithOptions(t *testing.T) {
+	cert, err := NewCertWithOptions(CertOptions{
+		KeyType:      KeyTypeECDSA,
+		KeyBits:      384,
+		ValidFor:     time.Hour,
+		IPAddresses:  []net.IP{net.ParseIP("10.0.0.1")},
+		DNSNames:     []string{"myservice.internal"},
+		Organization: []string{"Acme Corp"},
+		CommonName:   "myservice",
+	})
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if key, ok := cert.PrivateKey.(*ecdsa.PrivateKey); !ok || key.Curve != elliptic.P384() {
+		t.Errorf("Expected a P-384 ECDSA private key, got %T\n", cert.PrivateKey)
+	}
+
+	x509Cert, err := x509.ParseCertificate(cert.Certificate[0])
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if x509Cert.NotAfter.After(time.Now().Add(time.Hour)) {
+		t.Errorf("Certificate expiration date is %v, expected within an hour\n", x509Cert.NotAfter)
+	}
+
+	if len(x509Cert.IPAddresses) != 1 || !x509Cert.IPAddresses[0].Equal(net.ParseIP("10.0.0.1")) {
+		t.Errorf("Unexpected IP addresses: %v\n", x509Cert.IPAddresses)
+	}
+
+	if len(x509Cert.DNSNames) != 1 || x509Cert.DNSNames[0] != "myservice.internal" {
+		t.Errorf("Unexpected DNS names: %v\n", x509Cert.DNSNames)
+	}
+
+	if x509Cert.Subject.CommonName != "myservice" || x509Cert.Subject.Organization[0] != "Acme Corp" {
+		t.Errorf("Unexpected subject: %v\n", x509Cert.Subject)
+	}
+}
+
+func TestNewCertWithOptionsInvalid(t *testing.T) {
+	invalid := []CertOptions{
+		{KeyType: KeyTypeECDSA, KeyBits: 128},
+		{KeyType: KeyType(42)},
+		{ValidFor: -time.Hour},
+	}
+
+	for _, opts := range invalid {
+		if _, err := NewCertWithOptions(opts); err == nil {
+			t.Errorf("Expected an error for %+v\n", opts)
+		}
+	}
+}
+
+func TestNewCertWithFunctionalOptions(t *testing.T) {
+	cert, err := NewCert(
+		WithEd25519(),
+		WithDNSNames("localhost", "myservice"),
+		WithValidity(90*24*time.Hour),
+		WithOrganization("Acme Corp"),
+		WithCommonName("myservice"),
+	)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	x509Cert, err := x509.ParseCertificate(cert.Certificate[0])
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if x509Cert.SignatureAlgorithm != x509.PureEd25519 {
+		t.Errorf("Signature algorithm is %v, expected %v\n", x509Cert.SignatureAlgorithm, x509.PureEd25519)
+	}
+
+	if len(x509Cert.DNSNames) != 2 || x509Cert.DNSNames[1] != "myservice" {
+		t.Errorf("Unexpected DNS names: %v\n", x509Cert.DNSNames)
+	}
+
+	if x509Cert.NotAfter.After(time.Now().Add(90 * 24 * time.Hour)) {
+		t.Errorf("Certificate expiration date is %v, expected within 90 days\n", x509Cert.NotAfter)
+	}
+
+	if x509Cert.Subject.CommonName != "myservice" || x509Cert.Subject.Organization[0] != "Acme Corp" {
+		t.Errorf("Unexpected subject: %v\n", x509Cert.Subject)
+	}
+}
+
+func TestWithCommonNameFromAddr(t *testing.T) {
+	tests := []struct {
+		addr, want string
+	}{
+		{"myservice.internal:8443", "myservice.internal"},
+		{"myservice.internal", "myservice.internal"},
+		{"10.0.0.5:443", "10.0.0.5"},
+		{"[::1]:443", "::1"},
+		{"[::1]", "::1"},
+		{":8443", "localhost"},
+		{"0.0.0.0:443", "localhost"},
+		{"[::]:443", "localhost"},
+		{"", "localhost"},
+	}
+
+	for _, test := range tests {
+		cfg := newCertConfig([]Option{WithCommonNameFromAddr(test.addr)})
+		if cfg.commonName != test.want {
+			t.Errorf("Unexpected common name for %q: %q\n", test.addr, cfg.commonName)
+		}
+	}
+}
+
+func TestSubjectOptions(t *testing.T) {
+	cert, err := NewCert(WithEd25519(), WithCountry("US"), WithOrganization("Acme Corp"),
+		WithOrganizationalUnit("Engineering"), WithLocality("Springfield"), WithProvince("Oregon"),
+		WithPostalCode("97477"), WithCommonName("api.internal"))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	want := "CN=api.internal,OU=Engineering,O=Acme Corp,POSTALCODE=97477,L=Springfield,ST=Oregon,C=US"
+	if got := cert.Leaf.Subject.String(); got != want {
+		t.Errorf("Unexpected subject: %s\n", got)
+	}
+
+	for _, country := range []string{"USA", "us", ""} {
+		if _, err := NewCert(WithEd25519(), WithCountry(country)); err == nil {
+			t.Errorf("Expected an error for country %q\n", country)
+		}
+	}
+}
+
+func TestWithAutoKeyIDs(t *testing.T) {
+	cert, err := NewCert(WithEd25519(), WithAutoKeyIDs())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	pub := cert.Leaf.PublicKey.(ed25519.PublicKey)
+	want := sha1.Sum(pub)
+
+	if !bytes.Equal(cert.Leaf.SubjectKeyId, want[:]) || !bytes.Equal(cert.Leaf.AuthorityKeyId, want[:]) {
+		t.Errorf("Unexpected key identifiers: %x %x\n", cert.Leaf.SubjectKeyId, cert.Leaf.AuthorityKeyId)
+	}
+
+	ca, leaf, err := NewCAAndLeafCert(WithEd25519(), WithAutoKeyIDs())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if len(ca.Leaf.SubjectKeyId) == 0 || !bytes.Equal(leaf.Leaf.AuthorityKeyId, ca.Leaf.SubjectKeyId) {
+		t.Errorf("Unexpected authority key identifier: %x\n", leaf.Leaf.AuthorityKeyId)
+	}
+
+	if bytes.Equal(leaf.Leaf.SubjectKeyId, ca.Leaf.SubjectKeyId) {
+		t.Error("Expected distinct subject key identifiers")
+	}
+}
+
+func TestNewCertInvalidOptions(t *testing.T) {
+	if _, err := NewCert(WithValidity(0)); err == nil {
+		t.Error("Expected an error for a zero validity period")
+	}
+
+	if _, err := NewCert(WithECDSACurve(nil)); err == nil {
+		t.Error("Expected an error for a nil curve")
+	}
+}
+
+func TestWithIPv6Loopback(t *testing.T) {
+	ips := []net.IP{net.ParseIP("10.0.0.1").To4()}
+
+	cert, err := NewCert(WithEd25519(), WithIPAddresses(ips...), WithIPv6Loopback(), WithIPv6Loopback())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	x509Cert, err := x509.ParseCertificate(cert.Certificate[0])
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if len(x509Cert.IPAddresses) != 2 || !x509Cert.IPAddresses[1].Equal(net.IPv6loopback) {
+		t.Errorf("Unexpected IP addresses: %v\n", x509Cert.IPAddresses)
+	}
+
+	if len(ips) != 1 {
+		t.Errorf("Caller's IP address slice was modified: %v\n", ips)
+	}
+}
+
+func TestAppendIPMixedForms(t *testing.T) {
+	ips := []net.IP{net.ParseIP("127.0.0.1").To4()}
+
+	// net.ParseIP returns the 16 byte form, which must match the 4 byte form
+	if got := appendIP(ips, net.ParseIP("127.0.0.1")); len(got) != 1 {
+		t.Errorf("Expected the duplicate address to be skipped, got %v\n", got)
+	}
+}
+
+func TestWithURISANs(t *testing.T) {
+	spiffeID, err := url.Parse("spiffe://cluster.local/ns/default/sa/myservice")
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	cert, err := NewCert(WithEd25519(), WithURISANs(spiffeID))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	x509Cert, err := x509.ParseCertificate(cert.Certificate[0])
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if len(x509Cert.URIs) != 1 || x509Cert.URIs[0].String() != spiffeID.String() {
+		t.Errorf("Unexpected URIs: %v\n", x509Cert.URIs)
+	}
+
+	if _, err := NewCert(WithEd25519(), WithURISANs(nil)); err == nil {
+		t.Error("Expected an error for a nil URI")
+	}
+}
+
+func TestWithEmailAddresses(t *testing.T) {
+	cert, err := NewCert(WithEd25519(), WithEmailAddresses("ops@example.internal"))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	x509Cert, err := x509.ParseCertificate(cert.Certificate[0])
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if len(x509Cert.EmailAddresses) != 1 || x509Cert.EmailAddresses[0] != "ops@example.internal" {
+		t.Errorf("Unexpected email addresses: %v\n", x509Cert.EmailAddresses)
+	}
+
+	for _, addr := range []string{"ops.example.internal", "@example.internal", "ops@", "ops@a@b", "ops @example.internal"} {
+		if _, err := NewCert(WithEd25519(), WithEmailAddresses(addr)); err == nil {
+			t.Errorf("Expected an error for %q\n", addr)
+		}
+	}
+}
+
+func TestWithNotBefore(t *testing.T) {
+	notBefore := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	cert, err := NewCert(WithEd25519(), WithNotBefore(notBefore), WithValidity(24*time.Hour))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	x509Cert, err := x509.ParseCertificate(cert.Certificate[0])
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if !x509Cert.NotBefore.Equal(notBefore) || !x509Cert.NotAfter.Equal(notBefore.Add(24*time.Hour)) {
+		t.Errorf("Unexpected validity period: %v - %v\n", x509Cert.NotBefore, x509Cert.NotAfter)
+	}
+}
+
+func TestWithNotBeforeOffset(t *testing.T) {
+	start := time.Now().Truncate(time.Second)
+
+	cert, err := NewCert(WithEd25519(), WithValidity(time.Hour))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	// The default grace period back-dates NotBefore without shortening the validity
+	if notBefore := cert.Leaf.NotBefore; notBefore.After(start.Add(-time.Minute+time.Second)) || notBefore.Before(start.Add(-2*time.Minute)) {
+		t.Errorf("Unexpected NotBefore with the default grace period: %v\n", notBefore)
+	}
+
+	// The back-dating shifts the validity period rather than widening it
+	if span := cert.Leaf.NotAfter.Sub(cert.Leaf.NotBefore); span != time.Hour {
+		t.Errorf("Validity period spans %v, expected exactly an hour\n", span)
+	}
+
+	strict, err := NewCert(WithEd25519(), WithNotBeforeOffset(0))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if strict.Leaf.NotBefore.Before(start) {
+		t.Errorf("Unexpected NotBefore without a grace period: %v\n", strict.Leaf.NotBefore)
+	}
+
+	browser, err := NewCert(WithEd25519(), WithValidity(398*24*time.Hour))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if err := ValidateProfile(browser.Leaf, ProfileBrowserTLS); err != nil {
+		t.Errorf("Unexpected error for a 398 day certificate: %v\n", err)
+	}
+
+	if _, err := NewCert(WithEd25519(), WithNotBeforeOffset(-time.Minute)); err == nil {
+		t.Error("Expected an error for a negative offset")
+	}
+}
+
+func TestWithRSAKeyBits(t *testing.T) {
+	cert, err := NewCert(WithRSAKeyBits(1024))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if key, ok := cert.PrivateKey.(*rsa.PrivateKey); !ok || key.N.BitLen() != 1024 {
+		t.Errorf("Expected a 1024 bit RSA private key\n")
+	}
+
+	if _, err := NewCert(WithRSAKeyBits(256)); err == nil {
+		t.Error("Expected an error for a 256 bit RSA key")
+	}
+
+	if _, err := NewCertWithOptions(CertOptions{KeyBits: 256}); err == nil {
+		t.Error("Expected an error for a 256 bit RSA key")
+	}
+}
+
+func TestWithPKCS8Key(t *testing.T) {
+	for _, pkcs8 := range []bool{false, true} {
+		opts := []Option{WithECDSACurve(elliptic.P256())}
+		want := "EC PRIVATE KEY"
+		if pkcs8 {
+			opts = append(opts, WithPKCS8Key())
+			want = "PRIVATE KEY"
+		}
+
+		certPEM, keyPEM, err := NewCertPEM(opts...)
+
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+
+		if b, _ := pem.Decode(keyPEM); b == nil || b.Type != want {
+			t.Errorf("Expected a %s PEM block, got %q\n", want, keyPEM)
+		}
+
+		if _, err := tls.X509KeyPair(certPEM, keyPEM); err != nil {
+			t.Errorf("Unexpected error: %v\n", err)
+		}
+	}
+}
+
+func TestWithOCSPServer(t *testing.T) {
+	cert, err := NewCert(WithEd25519(), WithOCSPServer("http://ocsp.internal"))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if got := cert.Leaf.OCSPServer; len(got) != 1 || got[0] != "http://ocsp.internal" {
+		t.Errorf("Unexpected OCSP servers: %v\n", got)
+	}
+}
+
+func TestWithKeyUsage(t *testing.T) {
+	cert, err := NewCert(append(CodeSigningProfile(), WithEd25519())...)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if cert.Leaf.KeyUsage != x509.KeyUsageDigitalSignature {
+		t.Errorf("Unexpected key usage: %v\n", cert.Leaf.KeyUsage)
+	}
+
+	if eku := cert.Leaf.ExtKeyUsage; len(eku) != 1 || eku[0] != x509.ExtKeyUsageCodeSigning {
+		t.Errorf("Unexpected extended key usage: %v\n", eku)
+	}
+
+	// The CA keeps its own key usages
+	caCert, leafCert, err := NewCAAndLeafCert(append(ServerAuthProfile(), WithEd25519())...)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if ca := mustParseCert(t, caCert); ca.KeyUsage&x509.KeyUsageCertSign == 0 {
+		t.Error("Expected the CA to keep the certificate signing key usage")
+	}
+
+	if eku := mustParseCert(t, leafCert).ExtKeyUsage; len(eku) != 1 || eku[0] != x509.ExtKeyUsageServerAuth {
+		t.Errorf("Unexpected extended key usage: %v\n", eku)
+	}
+}
+
+func TestProfilesLint(t *testing.T) {
+	profiles := map[string][]Option{
+		"server":       ServerAuthProfile(),
+		"client":       ClientAuthProfile(),
+		"code signing": CodeSigningProfile(),
+	}
+
+	for name, profile := range profiles {
+		cert, err := NewCert(append(profile, WithEd25519())...)
+
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v\n", name, err)
+		}
+
+		if cert.Leaf.IsCA {
+			t.Errorf("%s: expected a leaf certificate\n", name)
+		}
+
+		for _, r := range LintCert(cert.Leaf) {
+			if r.Level == LintError {
+				t.Errorf("%s: unexpected lint error %s: %s\n", name, r.Code, r.Message)
+			}
+		}
+	}
+}
+
+func TestWildcardDNSNames(t *testing.T) {
+	cert, err := NewCert(WithEd25519(), WithDNSNames("*.example.internal"))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	defer l.Close()
+
+	go func() {
+		conn, err := l.Accept()
+		if err == nil {
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert.Leaf)
+
+	conn, err := tls.Dial("tcp", l.Addr().String(), &tls.Config{ServerName: "sub.example.internal", RootCAs: roots})
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	conn.Close()
+
+	for _, name := range []string{"*", "*.*.example.com", "foo.*.example.com", "f*o.example.com"} {
+		if _, err := NewCert(WithEd25519(), WithDNSNames(name)); err == nil {
+			t.Errorf("Expected an error for %q\n", name)
+		}
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("entropy exhausted")
+}
+
+func TestWithRandReader(t *testing.T) {
+	if _, err := NewCert(WithEd25519(), WithRandReader(failingReader{})); err == nil {
+		t.Error("Expected an error for a failing reader")
+	}
+
+	// Enough bytes for the Ed25519 seed, but not for the serial number
+	r := bytes.NewReader(make([]byte, ed25519.SeedSize))
+	if _, err := NewCert(WithEd25519(), WithRandReader(r)); err == nil {
+		t.Error("Expected an error for an exhausted reader")
+	}
+}
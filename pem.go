@@ -0,0 +1,134 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var errNoPEMBlock = errors.New("privatetls: no PEM block found")
+
+// SplitPEMBundle splits concatenated PEM blocks, such as a fullchain.pem file
+// or a combined certificate and key file, into one PEM encoded slice per
+// block, in their original order. It fails on empty blocks and on data that
+// is not PEM encoded.
+func SplitPEMBundle(bundle []byte) ([][]byte, error) {
+	var blocks [][]byte
+
+	rest := bundle
+	for {
+		var b *pem.Block
+		b, rest = pem.Decode(rest)
+		if b == nil {
+			break
+		}
+
+		if len(b.Bytes) == 0 {
+			return nil, fmt.Errorf("privatetls: empty %s PEM block at index %d", b.Type, len(blocks))
+		}
+		blocks = append(blocks, pem.EncodeToMemory(b))
+	}
+
+	if len(bytes.TrimSpace(rest)) > 0 {
+		return nil, fmt.Errorf("privatetls: data after PEM block %d is not PEM encoded", len(blocks))
+	}
+
+	if len(blocks) == 0 {
+		return nil, errNoPEMBlock
+	}
+
+	return blocks, nil
+}
+
+// MergePEMBundle concatenates PEM blocks into a bundle, separating them with
+// newlines where needed, e.g. to build a fullchain.pem file
+func MergePEMBundle(blocks ...[]byte) []byte {
+	var bundle bytes.Buffer
+
+	for _, b := range blocks {
+		bundle.Write(b)
+		if len(b) > 0 && b[len(b)-1] != '\n' {
+			bundle.WriteByte('\n')
+		}
+	}
+
+	return bundle.Bytes()
+}
+
+// PEMToCertificate parses the first CERTIFICATE block of the PEM data,
+// skipping any other blocks
+func PEMToCertificate(pemBytes []byte) (*x509.Certificate, error) {
+	b := findPEMBlock(pemBytes, func(t string) bool { return t == "CERTIFICATE" })
+	if b == nil {
+		return nil, errors.New("privatetls: no CERTIFICATE PEM block found")
+	}
+
+	return x509.ParseCertificate(b.Bytes)
+}
+
+// CertificateToPEM PEM encodes the certificate
+func CertificateToPEM(cert *x509.Certificate) []byte {
+	return DERToPEM(cert.Raw, "CERTIFICATE")
+}
+
+// PEMToPrivateKey parses the first private key block of the PEM data, e.g.
+// "RSA PRIVATE KEY", "EC PRIVATE KEY" or "PRIVATE KEY", skipping any other
+// blocks. The PKCS1, EC and PKCS8 encodings are tried in turn, so mislabeled
+// blocks are parsed too. Use LoadEncryptedKey for encrypted keys.
+func PEMToPrivateKey(pemBytes []byte) (crypto.PrivateKey, error) {
+	b := findPEMBlock(pemBytes, func(t string) bool { return strings.HasSuffix(t, "PRIVATE KEY") })
+	if b == nil {
+		return nil, errors.New("privatetls: no PRIVATE KEY PEM block found")
+	}
+
+	if x509.IsEncryptedPEMBlock(b) {
+		return nil, errors.New("privatetls: private key is encrypted")
+	}
+
+	return parsePrivateKey(b.Bytes)
+}
+
+// Return the first PEM block with a matching type, or nil
+func findPEMBlock(pemBytes []byte, match func(blockType string) bool) *pem.Block {
+	for rest := pemBytes; ; {
+		var b *pem.Block
+		if b, rest = pem.Decode(rest); b == nil || match(b.Type) {
+			return b
+		}
+	}
+}
+
+// Parse a DER encoded private key, trying the PKCS1, EC and PKCS8 encodings
+func parsePrivateKey(der []byte) (crypto.PrivateKey, error) {
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, errors.New("privatetls: unsupported private key encoding")
+	}
+
+	return key, nil
+}
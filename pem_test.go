@@ -0,0 +1,98 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/elliptic"
+	"crypto/tls"
+	"testing"
+)
+
+func TestSplitAndMergePEMBundle(t *testing.T) {
+	certPEM, keyPEM, err := NewCertPEM(WithEd25519())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	bundle := MergePEMBundle(certPEM, bytes.TrimSpace(keyPEM))
+	blocks, err := SplitPEMBundle(bundle)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if len(blocks) != 2 || !bytes.Equal(blocks[0], certPEM) || !bytes.Equal(blocks[1], keyPEM) {
+		t.Errorf("Unexpected blocks: %q\n", blocks)
+	}
+
+	for _, bad := range []string{"", "not PEM", string(certPEM) + "trailing garbage", "-----BEGIN CERTIFICATE-----\n-----END CERTIFICATE-----\n"} {
+		if _, err := SplitPEMBundle([]byte(bad)); err == nil {
+			t.Errorf("Expected an error for %q\n", bad)
+		}
+	}
+}
+
+func TestPEMConversions(t *testing.T) {
+	for _, opt := range []Option{WithRSAKeyBits(1024), WithECDSACurve(elliptic.P256()), WithEd25519(), WithPKCS8Key()} {
+		certPEM, keyPEM, err := NewCertPEM(opt)
+
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+
+		bundle := MergePEMBundle(keyPEM, certPEM)
+		cert, err := PEMToCertificate(bundle)
+
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+
+		if !bytes.Equal(CertificateToPEM(cert), certPEM) {
+			t.Error("Expected the certificate to round-trip\n")
+		}
+
+		key, err := PEMToPrivateKey(bundle)
+
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+
+		if _, err := tls.X509KeyPair(certPEM, encodeKey(t, key)); err != nil {
+			t.Errorf("Unexpected error: %v\n", err)
+		}
+	}
+
+	if _, err := PEMToCertificate([]byte("garbage")); err == nil {
+		t.Error("Expected an error for data without a certificate")
+	}
+
+	if _, err := PEMToPrivateKey([]byte("garbage")); err == nil {
+		t.Error("Expected an error for data without a key")
+	}
+}
+
+func encodeKey(t *testing.T, key crypto.PrivateKey) []byte {
+	t.Helper()
+
+	keyPEM, err := encodePrivateKey(key)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	return keyPEM
+}
@@ -0,0 +1,166 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// PinSHA256 returns the base64-encoded SHA-256 hash of the DER encoded
+// certificate, the format of the pins of NewPinnedTransport
+func PinSHA256(cert *x509.Certificate) string {
+	return pinOf(cert.Raw)
+}
+
+// NewPinnedTransport returns a transport that only connects to the pinned
+// hosts when they present a certificate with one of the accepted pins, see
+// PinSHA256. The pins map host names, or wildcards such as *.example.com
+// matching a single label, to their accepted pins. A pin of the leaf is
+// trusted without verifying its chain, as suits self-signed certificates. A
+// pin of an intermediate or root, e.g. of a private CA, only counts when the
+// chain verifies against TLSClientConfig.RootCAs, or the system roots, and
+// the certificate is part of the verified chain. With InsecureSkipVerify set
+// in TLSClientConfig, only leaf pins are accepted. Hosts without pins are
+// verified as usual.
+//
+// Since VerifyPeerCertificate is not told the host, the transport dials TLS
+// with a clone of its TLSClientConfig per connection, with the callback
+// checking the pins of the host. Changes to TLSClientConfig still apply. The
+// transport does not use a proxy, since TLS through a proxy would bypass the
+// pinned dialer.
+func NewPinnedTransport(pins map[string][]string) *http.Transport {
+	patterns := make(map[string]map[string]bool, len(pins))
+	for host, list := range pins {
+		accepted := make(map[string]bool, len(list))
+		for _, pin := range list {
+			accepted[pin] = true
+		}
+		patterns[normalizeServerName(host)] = accepted
+	}
+
+	t := &http.Transport{
+		TLSClientConfig:   &tls.Config{},
+		ForceAttemptHTTP2: true,
+	}
+
+	t.DialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg := t.TLSClientConfig.Clone()
+		if cfg.ServerName == "" {
+			cfg.ServerName = host
+		}
+
+		if accepted := lookupPins(patterns, host); accepted != nil {
+			leafOnly := cfg.InsecureSkipVerify
+			roots, serverName := cfg.RootCAs, cfg.ServerName
+
+			// The pins replace the chain verification of the TLS stack
+			cfg.InsecureSkipVerify = true
+			cfg.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+				if verifyPins(accepted, rawCerts, leafOnly, roots, serverName) {
+					return nil
+				}
+				return fmt.Errorf("privatetls: certificate of %s does not match its pins", host)
+			}
+		}
+
+		d := &tls.Dialer{Config: cfg}
+		return d.DialContext(ctx, network, addr)
+	}
+
+	return t
+}
+
+// Report whether the leaf matches a pin, or, unless leafOnly, whether the
+// chain verifies and one of its verified certificates matches a pin. Only
+// the leaf is trusted without verification, since a server can send any
+// other certificate along with its own.
+func verifyPins(accepted map[string]bool, rawCerts [][]byte, leafOnly bool, roots *x509.CertPool, serverName string) bool {
+	if len(rawCerts) == 0 {
+		return false
+	}
+
+	if accepted[pinOf(rawCerts[0])] {
+		return true
+	}
+
+	if leafOnly {
+		return false
+	}
+
+	certs := make([]*x509.Certificate, len(rawCerts))
+	for i, der := range rawCerts {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return false
+		}
+		certs[i] = cert
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	chains, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		DNSName:       serverName,
+	})
+	if err != nil {
+		return false
+	}
+
+	for _, chain := range chains {
+		for _, cert := range chain {
+			if accepted[pinOf(cert.Raw)] {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Find the pins of the host, by exact name first, then by wildcard
+func lookupPins(patterns map[string]map[string]bool, host string) map[string]bool {
+	name := normalizeServerName(host)
+	if accepted, ok := patterns[name]; ok {
+		return accepted
+	}
+
+	if _, parent, ok := strings.Cut(name, "."); ok {
+		return patterns["*."+parent]
+	}
+
+	return nil
+}
+
+// Hash the DER encoded certificate
+func pinOf(der []byte) string {
+	sum := sha256.Sum256(der)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
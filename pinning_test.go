@@ -0,0 +1,142 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewPinnedTransport(t *testing.T) {
+	cert, err := NewCert(WithEd25519())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	s.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	s.StartTLS()
+	defer s.Close()
+
+	tests := []struct {
+		pins map[string][]string
+		ok   bool
+	}{
+		{map[string][]string{"127.0.0.1": {"bm90IHRoZSBwaW4=", PinSHA256(cert.Leaf)}}, true},
+		{map[string][]string{"127.0.0.1": {"bm90IHRoZSBwaW4="}}, false},
+		{map[string][]string{"other.internal": {PinSHA256(cert.Leaf)}}, false}, // Verified as usual
+	}
+
+	for i, test := range tests {
+		client := &http.Client{Transport: NewPinnedTransport(test.pins)}
+
+		resp, err := client.Get(s.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+
+		if (err == nil) != test.ok {
+			t.Errorf("Unexpected result for pins %d: %v\n", i, err)
+		}
+	}
+}
+
+func TestLookupPins(t *testing.T) {
+	patterns := map[string]map[string]bool{
+		"api.internal":    {"exact": true},
+		"*.mesh.internal": {"wildcard": true},
+	}
+
+	tests := []struct {
+		host, want string
+	}{
+		{"API.internal.", "exact"},
+		{"a.mesh.internal", "wildcard"},
+		{"a.b.mesh.internal", ""},
+		{"mesh.internal", ""},
+	}
+
+	for _, test := range tests {
+		var got string
+		for pin := range lookupPins(patterns, test.host) {
+			got = pin
+		}
+
+		if got != test.want {
+			t.Errorf("Unexpected pin for %s: %q\n", test.host, got)
+		}
+	}
+}
+
+func TestNewPinnedTransportChain(t *testing.T) {
+	caCert, leafCert, err := NewCAAndLeafCert(WithEd25519())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	attacker, err := NewCert(WithEd25519())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	// An attacker presenting its own leaf followed by the pinned certificate
+	forged := attacker
+	forged.Certificate = [][]byte{attacker.Certificate[0], leafCert.Certificate[0]}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(caCert.Leaf)
+
+	tests := []struct {
+		serve              tls.Certificate
+		pin                string
+		insecureSkipVerify bool
+		ok                 bool
+	}{
+		{leafCert, PinSHA256(caCert.Leaf), false, true},
+		{leafCert, PinSHA256(caCert.Leaf), true, false}, // Only leaf pins without verification
+		{leafCert, PinSHA256(leafCert.Leaf), true, true},
+		{forged, PinSHA256(leafCert.Leaf), false, false},
+		{forged, PinSHA256(leafCert.Leaf), true, false},
+	}
+
+	for i, test := range tests {
+		s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		s.TLS = &tls.Config{Certificates: []tls.Certificate{test.serve}}
+		s.StartTLS()
+
+		transport := NewPinnedTransport(map[string][]string{"127.0.0.1": {test.pin}})
+		transport.TLSClientConfig.RootCAs = roots
+		transport.TLSClientConfig.InsecureSkipVerify = test.insecureSkipVerify
+
+		resp, err := (&http.Client{Transport: transport}).Get(s.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		s.Close()
+
+		if (err == nil) != test.ok {
+			t.Errorf("Unexpected result for test %d: %v\n", i, err)
+		}
+	}
+
+	if NewPinnedTransport(nil).Proxy != nil {
+		t.Error("Expected the pinned transport not to use a proxy")
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// ExportPKCS12 encodes the private key, the leaf certificate and the rest of
+// the certificate chain as a password protected PKCS12 (.p12 / .pfx) file, the
+// format expected by many Windows and Java tools. The file is encrypted with
+// AES-256 and a SHA-256 MAC, which recent versions of Java, OpenSSL and
+// Windows can read.
+func ExportPKCS12(cert tls.Certificate, password string) ([]byte, error) {
+	leaf, err := leafCertificate(cert)
+	if err != nil {
+		return nil, err
+	}
+
+	var caCerts []*x509.Certificate
+	for _, der := range cert.Certificate[1:] {
+		caCert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, err
+		}
+		caCerts = append(caCerts, caCert)
+	}
+
+	return pkcs12.Modern.Encode(cert.PrivateKey, leaf, caCerts, password)
+}
+
+// WritePKCS12File writes the certificate encoded by ExportPKCS12 to path. The
+// file is only readable by its owner.
+func WritePKCS12File(path string, cert tls.Certificate, password string) error {
+	pfxData, err := ExportPKCS12(cert, password)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, pfxData, keyFileMode)
+}
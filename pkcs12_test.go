@@ -0,0 +1,86 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+func TestExportPKCS12(t *testing.T) {
+	caCert, _, err := NewCAAndLeafCert(WithECDSACurve(elliptic.P256()))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	ca, caKey, err := parseCertAndSigner(caCert)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	intermediateCert, err := NewIntermediateCA(ca, caKey, WithECDSACurve(elliptic.P256()))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	intermediate, intermediateKey, err := parseCertAndSigner(intermediateCert)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	cert, err := NewSignedLeafCert(intermediate, intermediateKey, WithECDSACurve(elliptic.P256()))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "cert.p12")
+
+	if err := WritePKCS12File(path, cert, "secret"); err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	pfxData, err := os.ReadFile(path)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	_, leaf, caCerts, err := pkcs12.DecodeChain(pfxData, "secret")
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if !bytes.Equal(leaf.Raw, cert.Certificate[0]) {
+		t.Error("Decoded leaf does not match the exported certificate")
+	}
+
+	if len(caCerts) != 1 || !bytes.Equal(caCerts[0].Raw, intermediate.Raw) {
+		t.Errorf("Expected the intermediate in the CA chain, got %d certificates\n", len(caCerts))
+	}
+
+	if _, _, _, err := pkcs12.DecodeChain(pfxData, "wrong"); err == nil {
+		t.Error("Expected an error for a wrong password")
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto/tls"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// NewCertPool generates size distinct certificates as configured by the
+// options, in parallel on all CPUs, and delivers them on the certificate
+// channel as they are ready. A failed generation sends its error on the error
+// channel and does not affect the others. Both channels are buffered for
+// size values and are closed once all certificates are generated, so they
+// can be ranged over.
+//
+// The returned function stops the generation early: it waits for the
+// certificates in progress, discards the undelivered ones and closes the
+// channels. It is safe to call more than once.
+func NewCertPool(size int, opts ...Option) (chan tls.Certificate, chan error, func()) {
+	if size < 0 {
+		size = 0
+	}
+
+	certs := make(chan tls.Certificate, size)
+	errs := make(chan error, size)
+	done := make(chan struct{})
+	closed := make(chan struct{})
+
+	workers := runtime.NumCPU()
+	if workers > size {
+		workers = size
+	}
+
+	var wg sync.WaitGroup
+	remaining := int64(size)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for atomic.AddInt64(&remaining, -1) >= 0 {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				// The channels have room for every certificate, so sending never blocks
+				if cert, err := NewCert(opts...); err != nil {
+					errs <- err
+				} else {
+					certs <- cert
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(certs)
+		close(errs)
+		close(closed)
+	}()
+
+	var stopOnce sync.Once
+	stop := func() {
+		stopOnce.Do(func() {
+			close(done)
+			<-closed
+
+			for range certs {
+			}
+			for range errs {
+			}
+		})
+	}
+
+	return certs, errs, stop
+}
@@ -0,0 +1,66 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNewCertPool(t *testing.T) {
+	certs, errs, stop := NewCertPool(8, WithEd25519())
+	defer stop()
+
+	var seen [][]byte
+	for cert := range certs {
+		for _, der := range seen {
+			if bytes.Equal(der, cert.Certificate[0]) {
+				t.Error("Expected distinct certificates")
+			}
+		}
+		seen = append(seen, cert.Certificate[0])
+	}
+
+	if len(seen) != 8 {
+		t.Errorf("Expected 8 certificates, got %d\n", len(seen))
+	}
+
+	for err := range errs {
+		t.Errorf("Unexpected error: %v\n", err)
+	}
+}
+
+func TestNewCertPoolErrors(t *testing.T) {
+	certs, errs, stop := NewCertPool(3, WithRSAKeyBits(1))
+	defer stop()
+
+	n := 0
+	for range errs {
+		n++
+	}
+
+	if n != 3 || len(certs) != 0 {
+		t.Errorf("Expected 3 errors and no certificates, got %d errors and %d certificates\n", n, len(certs))
+	}
+}
+
+func TestNewCertPoolStop(t *testing.T) {
+	certs, _, stop := NewCertPool(1000, WithEd25519())
+	stop()
+	stop()
+
+	if _, ok := <-certs; ok {
+		t.Error("Expected the certificate channel to be drained and closed")
+	}
+}
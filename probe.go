@@ -0,0 +1,74 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"time"
+)
+
+// TLSProbeResult describes the TLS session negotiated with a server
+type TLSProbeResult struct {
+	Certificates       []*x509.Certificate // As presented by the server, leaf first
+	Version            uint16              // e.g. tls.VersionTLS13, see tls.VersionName
+	CipherSuite        uint16              // See tls.CipherSuiteName
+	NegotiatedProtocol string              // ALPN protocol, empty if none was negotiated
+	OCSPStapled        bool
+	OCSPResponse       []byte // Stapled OCSP response, if any
+}
+
+// TLSProbe connects to the server at addr and reports the certificates it
+// presents and the parameters of the session, like openssl s_client -connect,
+// e.g. to check that a running server presents the expected certificate. The
+// certificates are not verified, so servers with self-signed certificates can
+// be probed. The client sends the host of addr as SNI and offers h2 and
+// http/1.1 with ALPN. The timeout covers both the connection and the
+// handshake.
+func TLSProbe(addr string, timeout time.Duration) (*TLSProbeResult, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	d := &tls.Dialer{
+		Config: &tls.Config{
+			ServerName:         host,
+			NextProtos:         []string{"h2", "http/1.1"},
+			InsecureSkipVerify: true,
+		},
+	}
+
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	state := conn.(*tls.Conn).ConnectionState()
+
+	return &TLSProbeResult{
+		Certificates:       state.PeerCertificates,
+		Version:            state.Version,
+		CipherSuite:        state.CipherSuite,
+		NegotiatedProtocol: state.NegotiatedProtocol,
+		OCSPStapled:        len(state.OCSPResponse) > 0,
+		OCSPResponse:       state.OCSPResponse,
+	}, nil
+}
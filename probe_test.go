@@ -0,0 +1,61 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestTLSProbe(t *testing.T) {
+	s, cert, err := NewServer("127.0.0.1:0", WithServerCertOptions(WithEd25519()))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	l, err := net.Listen("tcp", s.Addr)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	go s.ServeTLS(l, "", "")
+	defer s.Shutdown(context.Background())
+
+	result, err := TLSProbe(l.Addr().String(), 5*time.Second)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if len(result.Certificates) != 1 || !result.Certificates[0].Equal(cert.Leaf) {
+		t.Error("Expected the server certificate")
+	}
+
+	if result.Version != tls.VersionTLS13 || tls.CipherSuiteName(result.CipherSuite) == "" {
+		t.Errorf("Unexpected version or cipher suite: %x %x\n", result.Version, result.CipherSuite)
+	}
+
+	if result.NegotiatedProtocol != "h2" || result.OCSPStapled {
+		t.Errorf("Unexpected protocol or OCSP stapling: %q %v\n", result.NegotiatedProtocol, result.OCSPStapled)
+	}
+
+	if _, err := TLSProbe("127.0.0.1", time.Second); err == nil {
+		t.Error("Expected an error for an address without a port")
+	}
+}
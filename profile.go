@@ -0,0 +1,128 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	maxBrowserValidity     = 398 * 24 * time.Hour
+	minProfileRSAKeyLength = 2048
+	minCodeSigningRSABits  = 3072
+)
+
+// CertProfile identifies a set of requirements a certificate is checked against
+type CertProfile int
+
+// The supported certificate profiles
+const (
+	// ProfileBrowserTLS covers the requirements browsers enforce on server certificates
+	ProfileBrowserTLS CertProfile = iota
+	// ProfileMTLS covers client certificates used for mutual TLS
+	ProfileMTLS
+	// ProfileCodeSigning covers code signing certificates
+	ProfileCodeSigning
+)
+
+// ValidateProfile checks the certificate against the requirements of the
+// profile. ProfileBrowserTLS rejects certificates valid for more than 398
+// days, which Safari and Chrome refuse, certificates without SANs, SHA-1
+// signatures and RSA keys shorter than 2048 bits. The returned error lists
+// every violation, see errors.Join.
+func ValidateProfile(cert *x509.Certificate, profile CertProfile) error {
+	var errs []error
+	violation := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("privatetls: "+format, args...))
+	}
+
+	minRSABits := minProfileRSAKeyLength
+
+	switch profile {
+	case ProfileBrowserTLS:
+		if validity := cert.NotAfter.Sub(cert.NotBefore); validity > maxBrowserValidity {
+			// Round up, so that a validity just over the limit is not reported as equal to it
+			days := (validity + 24*time.Hour - 1) / (24 * time.Hour)
+			violation("validity of %d days exceeds the browser limit of 398 days", days)
+		}
+
+		if len(cert.DNSNames) == 0 && len(cert.IPAddresses) == 0 {
+			violation("certificate has no DNS or IP address SANs")
+		}
+
+		if !hasExtKeyUsage(cert, x509.ExtKeyUsageServerAuth) {
+			violation("certificate is not valid for server authentication")
+		}
+	case ProfileMTLS:
+		if !hasExtKeyUsage(cert, x509.ExtKeyUsageClientAuth) {
+			violation("certificate is not valid for client authentication")
+		}
+
+		if cert.KeyUsage != 0 && cert.KeyUsage&x509.KeyUsageDigitalSignature == 0 {
+			violation("key usage does not allow digital signatures")
+		}
+	case ProfileCodeSigning:
+		minRSABits = minCodeSigningRSABits
+
+		if !hasExtKeyUsage(cert, x509.ExtKeyUsageCodeSigning) {
+			violation("certificate is not valid for code signing")
+		}
+
+		if cert.IsCA {
+			violation("code signing certificate must not be a CA")
+		}
+	default:
+		return fmt.Errorf("privatetls: unknown certificate profile %d", profile)
+	}
+
+	if isSHA1Signature(cert.SignatureAlgorithm) {
+		violation("deprecated signature algorithm %v", cert.SignatureAlgorithm)
+	}
+
+	if k, ok := cert.PublicKey.(*rsa.PublicKey); ok && k.N.BitLen() < minRSABits {
+		violation("RSA key length %d is below the minimum of %d bits", k.N.BitLen(), minRSABits)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Report whether the certificate is valid for the extended key usage. A
+// certificate without extended key usages is valid for any.
+func hasExtKeyUsage(cert *x509.Certificate, usage x509.ExtKeyUsage) bool {
+	if len(cert.ExtKeyUsage) == 0 && len(cert.UnknownExtKeyUsage) == 0 {
+		return true
+	}
+
+	for _, u := range cert.ExtKeyUsage {
+		if u == usage || u == x509.ExtKeyUsageAny {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Report whether the signature algorithm relies on SHA-1 or weaker hashes
+func isSHA1Signature(alg x509.SignatureAlgorithm) bool {
+	switch alg {
+	case x509.MD2WithRSA, x509.MD5WithRSA, x509.SHA1WithRSA, x509.DSAWithSHA1, x509.ECDSAWithSHA1:
+		return true
+	default:
+		return false
+	}
+}
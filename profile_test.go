@@ -0,0 +1,81 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto/x509"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateProfile(t *testing.T) {
+	cert, err := NewCert(WithEd25519())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if err := ValidateProfile(cert.Leaf, ProfileBrowserTLS); err != nil {
+		t.Errorf("Unexpected error: %v\n", err)
+	}
+
+	if err := ValidateProfile(cert.Leaf, ProfileMTLS); err != nil {
+		t.Errorf("Unexpected error: %v\n", err)
+	}
+
+	if err := ValidateProfile(cert.Leaf, ProfileCodeSigning); err == nil {
+		t.Error("Expected an error for a TLS certificate checked as a code signing certificate")
+	}
+
+	if err := ValidateProfile(cert.Leaf, CertProfile(42)); err == nil {
+		t.Error("Expected an error for an unknown profile")
+	}
+}
+
+func TestValidateProfileListsAllViolations(t *testing.T) {
+	cert, err := NewCert(WithRSAKeyBits(1024), WithValidity(2*365*24*time.Hour), WithIPAddresses(), WithDNSNames())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	// Patch the parsed certificate, since SHA-1 certificates cannot be created
+	leaf := *cert.Leaf
+	leaf.SignatureAlgorithm = x509.SHA1WithRSA
+
+	err = ValidateProfile(&leaf, ProfileBrowserTLS)
+	if err == nil {
+		t.Fatal("Expected profile violations")
+	}
+
+	for _, want := range []string{"398 days", "SANs", "SHA1", "RSA key length"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected a violation mentioning %q, got: %v\n", want, err)
+		}
+	}
+}
+
+func TestValidateProfileValidityRounding(t *testing.T) {
+	cert, err := NewCert(WithEd25519(), WithValidity(398*24*time.Hour+5*time.Second))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	err = ValidateProfile(cert.Leaf, ProfileBrowserTLS)
+	if err == nil || !strings.Contains(err.Error(), "validity of 399 days") {
+		t.Errorf("Expected the validity to be rounded up to 399 days, got: %v\n", err)
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// ReloadingCertificate serves a certificate loaded from a pair of PEM files,
+// and reloads it when the files change. Use its GetCertificate method as
+// tls.Config.GetCertificate to pick up a regenerated certificate without
+// restarting the server. Handshakes already in progress keep the certificate
+// they started with.
+type ReloadingCertificate struct {
+	certPath, keyPath string
+	logger            *slog.Logger
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewReloadingCertificate loads the certificate and key files with LoadCert,
+// and checks them for changes every interval. If a reload fails, e.g. because
+// only one of the files has been written so far, the previous certificate is
+// kept and the reload is retried on the next check. See WithLogger to log the
+// reloads.
+func NewReloadingCertificate(certPath, keyPath string, interval time.Duration, opts ...BackgroundOption) (*ReloadingCertificate, error) {
+	if interval <= 0 {
+		return nil, errors.New("privatetls: reload interval must be positive")
+	}
+
+	r := &ReloadingCertificate{
+		certPath: certPath,
+		keyPath:  keyPath,
+		logger:   newBackgroundConfig(opts).logger,
+		done:     make(chan struct{}),
+	}
+
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	go r.poll(interval)
+
+	return r, nil
+}
+
+// GetCertificate returns the most recently loaded certificate
+func (r *ReloadingCertificate) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.cert, nil
+}
+
+// Close stops checking the files for changes. The last loaded certificate
+// continues to be served.
+func (r *ReloadingCertificate) Close() error {
+	r.closeOnce.Do(func() {
+		close(r.done)
+	})
+
+	return nil
+}
+
+// Check the files on every tick until closed
+func (r *ReloadingCertificate) poll(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+			r.logger.Debug("privatetls: checking certificate files", "cert", r.certPath, "key", r.keyPath)
+
+			if !r.changed() {
+				continue
+			}
+
+			if err := r.reload(); err != nil {
+				r.logger.Error("privatetls: certificate reload failed", "cert", r.certPath, "error", err)
+				continue
+			}
+			r.logger.Info("privatetls: certificate reloaded", "cert", r.certPath)
+		}
+	}
+}
+
+// Report whether either file was modified since the last successful load
+func (r *ReloadingCertificate) changed() bool {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return !modTime.Equal(r.modTime)
+}
+
+// Load the files, replacing the served certificate on success
+func (r *ReloadingCertificate) reload() error {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+
+	cert, err := LoadCert(r.certPath, r.keyPath)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cert = &cert
+	r.modTime = modTime
+
+	return nil
+}
+
+// The most recent modification time of the two files
+func (r *ReloadingCertificate) latestModTime() (time.Time, error) {
+	var latest time.Time
+
+	for _, path := range []string{r.certPath, r.keyPath} {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		if fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+
+	return latest, nil
+}
@@ -0,0 +1,72 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReloadingCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+
+	if err := WriteCertFiles(certPath, keyPath, WithEd25519()); err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	r, err := NewReloadingCertificate(certPath, keyPath, 10*time.Millisecond)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	defer r.Close()
+
+	initial, _ := r.GetCertificate(nil)
+
+	if err := WriteCertFiles(certPath, keyPath, WithEd25519()); err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	// Make sure the change is visible on file systems with a coarse timestamp resolution
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(certPath, future, future)
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		current, _ := r.GetCertificate(nil)
+		if !bytes.Equal(current.Certificate[0], initial.Certificate[0]) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Error("Certificate was not reloaded after the files changed")
+}
+
+func TestReloadingCertificateInvalid(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, err := NewReloadingCertificate(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"), time.Second); err == nil {
+		t.Error("Expected an error for missing files")
+	}
+
+	if _, err := NewReloadingCertificate("cert.pem", "key.pem", 0); err == nil {
+		t.Error("Expected an error for a zero interval")
+	}
+}
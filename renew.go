@@ -0,0 +1,201 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// How long to wait before trying again when a renewal fails
+const renewRetryInterval = time.Minute
+
+// ManagedCert serves a certificate that is regenerated in the background
+// before it expires. Use its GetCertificate method as tls.Config.GetCertificate.
+type ManagedCert struct {
+	renewBefore time.Duration
+	gen         func() (tls.Certificate, error)
+	name        string
+	logger      *slog.Logger
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	notAfter time.Time
+
+	done      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+}
+
+// AutoRenew generates a certificate with gen, e.g. a closure calling NewCert,
+// and regenerates it every time it gets within renewBefore of its expiry.
+// When a renewal fails the current certificate keeps being served and the
+// renewal is retried a minute later. The generated certificates must be
+// valid for longer than renewBefore. See WithLogger to log the renewals.
+func AutoRenew(renewBefore time.Duration, gen func() (tls.Certificate, error), opts ...BackgroundOption) (*ManagedCert, error) {
+	if gen == nil {
+		return nil, errors.New("privatetls: certificate generator must not be nil")
+	}
+
+	if renewBefore < 0 {
+		return nil, errors.New("privatetls: renewal period must not be negative")
+	}
+
+	m := &ManagedCert{
+		renewBefore: renewBefore,
+		gen:         gen,
+		logger:      newBackgroundConfig(opts).logger,
+		done:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+
+	if err := m.renew(); err != nil {
+		return nil, err
+	}
+
+	if m.untilRenewal() <= 0 {
+		m.release()
+		return nil, errors.New("privatetls: certificate validity is shorter than the renewal period")
+	}
+
+	go m.run()
+
+	return m, nil
+}
+
+// GetCertificate returns the current certificate
+func (m *ManagedCert) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.cert, nil
+}
+
+// Name identifies the certificate in logs and metrics. It is the common name
+// of the first generated certificate, or its first SAN, followed by a number
+// such as "#2" when another ManagedCert still renewing has that name already.
+func (m *ManagedCert) Name() string {
+	return m.name
+}
+
+// Close stops the renewals and waits for the background goroutine to exit.
+// The current certificate continues to be served.
+func (m *ManagedCert) Close() error {
+	m.closeOnce.Do(func() {
+		close(m.done)
+		<-m.stopped
+		m.release()
+	})
+	<-m.stopped
+
+	return nil
+}
+
+// Free the name of the certificate and report that it stopped renewing
+func (m *ManagedCert) release() {
+	releaseCertName(m.name)
+
+	if o := currentObserver(); o != nil {
+		o.CertClosed(m.name)
+	}
+}
+
+// Renew the certificate whenever it is due, until closed
+func (m *ManagedCert) run() {
+	defer close(m.stopped)
+
+	wait := m.untilRenewal()
+	for {
+		timer := time.NewTimer(wait)
+
+		select {
+		case <-m.done:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		m.logger.Debug("privatetls: renewing certificate", "name", m.name)
+
+		if err := m.renew(); err != nil {
+			m.logger.Error("privatetls: certificate renewal failed", "name", m.name, "error", err,
+				"retry_in", renewRetryInterval)
+			m.logExpiry()
+			wait = renewRetryInterval
+			continue
+		}
+
+		// Never spin on a generator that returns short-lived certificates
+		if wait = m.untilRenewal(); wait <= 0 {
+			wait = renewRetryInterval
+		}
+	}
+}
+
+// Generate a new certificate and start serving it
+func (m *ManagedCert) renew() error {
+	start := time.Now()
+	cert, err := m.gen()
+	if err != nil {
+		return err
+	}
+	took := time.Since(start)
+
+	leaf, err := leafCertificate(cert)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	renewed := m.cert != nil
+	if m.name == "" {
+		m.name = reserveCertName(certName(leaf))
+	}
+	m.cert = &cert
+	m.notAfter = leaf.NotAfter
+	m.mu.Unlock()
+
+	m.logger.Info("privatetls: certificate generated", "name", m.name, "not_after", leaf.NotAfter,
+		"took", took, "renewal", renewed)
+
+	if o := currentObserver(); o != nil {
+		o.CertGenerated(m.name, leaf.NotAfter, took)
+		if renewed {
+			o.CertRenewed(m.name)
+		}
+	}
+
+	return nil
+}
+
+// Warn that the current certificate, which failed to renew, is about to expire
+func (m *ManagedCert) logExpiry() {
+	m.mu.RLock()
+	notAfter := m.notAfter
+	m.mu.RUnlock()
+
+	m.logger.Warn("privatetls: certificate nearing expiry", "name", m.name, "not_after", notAfter,
+		"expires_in", time.Until(notAfter).Round(time.Second))
+}
+
+// The time left until the current certificate is due for renewal
+func (m *ManagedCert) untilRenewal() time.Duration {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return time.Until(m.notAfter) - m.renewBefore
+}
@@ -0,0 +1,117 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAutoRenew(t *testing.T) {
+	gen := func() (tls.Certificate, error) {
+		return NewCert(WithEd25519(), WithValidity(3*time.Second), WithNotBeforeOffset(0))
+	}
+
+	m, err := AutoRenew(2*time.Second, gen)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	defer m.Close()
+
+	initial, _ := m.GetCertificate(nil)
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		current, _ := m.GetCertificate(nil)
+		if !bytes.Equal(current.Certificate[0], initial.Certificate[0]) {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	t.Error("Certificate was not renewed")
+}
+
+func TestAutoRenewInvalid(t *testing.T) {
+	if _, err := AutoRenew(time.Hour, nil); err == nil {
+		t.Error("Expected an error for a nil generator")
+	}
+
+	gen := func() (tls.Certificate, error) {
+		return NewCert(WithEd25519(), WithValidity(time.Hour))
+	}
+
+	if _, err := AutoRenew(2*time.Hour, gen); err == nil {
+		t.Error("Expected an error for a certificate shorter than the renewal period")
+	}
+}
+
+// A buffer safe for the concurrent writes of a logger
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
+
+func TestAutoRenewWithLogger(t *testing.T) {
+	var logs syncBuffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	var calls int32
+	gen := func() (tls.Certificate, error) {
+		if atomic.AddInt32(&calls, 1) > 1 {
+			return tls.Certificate{}, errors.New("generator failure")
+		}
+		return NewCert(WithEd25519(), WithValidity(3*time.Second), WithNotBeforeOffset(0))
+	}
+
+	m, err := AutoRenew(2*time.Second, gen, WithLogger(logger))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	defer m.Close()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) && !strings.Contains(logs.String(), "nearing expiry") {
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	for _, want := range []string{"level=INFO msg=\"privatetls: certificate generated\"", "level=DEBUG", "level=ERROR", "generator failure", "level=WARN"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("Expected %q in the logs:\n%s", want, logs.String())
+		}
+	}
+}
@@ -0,0 +1,221 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// NewCertPoolFromFiles builds a pool from the certificates of the PEM files,
+// e.g. to trust a private CA. Directories are scanned one level deep, for the
+// files ending in .crt or .pem; files there without certificates, such as
+// keys, are skipped. Any other blocks in the files are ignored. It fails if a
+// file or directory yields no certificate, or if one cannot be parsed.
+func NewCertPoolFromFiles(paths ...string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+
+	if err := appendCertFiles(pool, paths); err != nil {
+		return nil, err
+	}
+
+	return pool, nil
+}
+
+// NewCertPoolWithSystem builds a pool from the system roots, plus the
+// certificates of the files and directories read like NewCertPoolFromFiles.
+// This trusts an internal CA along with the public ones, without modifying the
+// trust store of the operating system.
+func NewCertPoolWithSystem(extraPaths ...string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		return nil, fmt.Errorf("privatetls: loading the system roots: %w", err)
+	}
+
+	if err := appendCertFiles(pool, extraPaths); err != nil {
+		return nil, err
+	}
+
+	return pool, nil
+}
+
+// Add the certificates of the files, and of the certificate files in the directories
+func appendCertFiles(pool *x509.CertPool, paths []string) error {
+	for _, path := range paths {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+
+		if !fi.IsDir() {
+			if n, err := appendCertFile(pool, path); err != nil {
+				return err
+			} else if n == 0 {
+				return fmt.Errorf("privatetls: no certificates found in %s", path)
+			}
+			continue
+		}
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return err
+		}
+
+		found := 0
+		for _, e := range entries {
+			if ext := filepath.Ext(e.Name()); e.IsDir() || (ext != ".crt" && ext != ".pem") {
+				continue
+			}
+
+			n, err := appendCertFile(pool, filepath.Join(path, e.Name()))
+			if err != nil {
+				return err
+			}
+			found += n
+		}
+
+		if found == 0 {
+			return fmt.Errorf("privatetls: no certificates found in directory %s", path)
+		}
+	}
+
+	return nil
+}
+
+// Add the certificates of the PEM file, returning their number
+func appendCertFile(pool *x509.CertPool, path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for rest := data; ; {
+		var b *pem.Block
+		if b, rest = pem.Decode(rest); b == nil {
+			return n, nil
+		}
+
+		if b.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(b.Bytes)
+		if err != nil {
+			return 0, fmt.Errorf("privatetls: parsing certificate %d of %s: %w", n, path, err)
+		}
+
+		pool.AddCert(cert)
+		n++
+	}
+}
+
+// DeduplicateCertPool returns a copy of the pool without duplicate
+// certificates, and the number of duplicates removed. x509.CertPool.AddCert
+// already skips certificates whose DER encoding was added before, so a pool
+// built by the standard library never holds duplicates and the count is
+// always 0 for one; use an IndexedCertPool to see which certificates of
+// overlapping sources were duplicates.
+func DeduplicateCertPool(pool *x509.CertPool) (*x509.CertPool, int, error) {
+	if pool == nil {
+		return nil, 0, errors.New("privatetls: certificate pool must not be nil")
+	}
+
+	return pool.Clone(), 0, nil
+}
+
+// IndexedCertPool collects certificates without duplicates, indexed by their
+// SHA-256 fingerprint and subject, e.g. to merge several sources of roots.
+// x509.CertPool.AddCert silently skips duplicates itself, so Add reports
+// them instead, and CertPool returns the result. It is safe for concurrent
+// use.
+type IndexedCertPool struct {
+	mu            sync.RWMutex
+	byFingerprint map[string]*x509.Certificate
+	bySubject     map[string][]*x509.Certificate
+	pool          *x509.CertPool
+}
+
+// NewIndexedCertPool returns an empty pool
+func NewIndexedCertPool() *IndexedCertPool {
+	return &IndexedCertPool{
+		byFingerprint: make(map[string]*x509.Certificate),
+		bySubject:     make(map[string][]*x509.Certificate),
+		pool:          x509.NewCertPool(),
+	}
+}
+
+// Add adds the certificate, unless a certificate with the same DER encoding
+// was added before, in which case it returns false
+func (p *IndexedCertPool) Add(cert *x509.Certificate) bool {
+	fp := FingerprintSHA256(cert)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.byFingerprint[fp]; ok {
+		return false
+	}
+
+	p.byFingerprint[fp] = cert
+	p.pool.AddCert(cert)
+
+	subject := cert.Subject.String()
+	p.bySubject[subject] = append(p.bySubject[subject], cert)
+	if cn := cert.Subject.CommonName; cn != "" && cn != subject {
+		p.bySubject[cn] = append(p.bySubject[cn], cert)
+	}
+
+	return true
+}
+
+// FindByFingerprint returns the certificate with the SHA-256 fingerprint, in
+// the format of FingerprintSHA256 or as plain hex digits, or nil
+func (p *IndexedCertPool) FindByFingerprint(fp string) *x509.Certificate {
+	sum, err := hex.DecodeString(strings.ReplaceAll(fp, ":", ""))
+	if err != nil {
+		return nil
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.byFingerprint[formatFingerprint(sum)]
+}
+
+// FindBySubject returns the certificates whose subject is name, either in its
+// String form, e.g. "CN=PrivateTLS Root CA,O=PrivateTLS", or as the common
+// name alone, in the order they were added
+func (p *IndexedCertPool) FindBySubject(name string) []*x509.Certificate {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return append([]*x509.Certificate(nil), p.bySubject[name]...)
+}
+
+// CertPool returns a copy of the certificates as an x509.CertPool, e.g. for
+// tls.Config.RootCAs
+func (p *IndexedCertPool) CertPool() *x509.CertPool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.pool.Clone()
+}
@@ -0,0 +1,186 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewCertPoolFromFiles(t *testing.T) {
+	dir := t.TempDir()
+	caDir := filepath.Join(dir, "cas")
+
+	if err := os.Mkdir(caDir, 0755); err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	var leaves []tls.Certificate
+	files := []string{filepath.Join(caDir, "first.crt"), filepath.Join(caDir, "second.pem"), filepath.Join(dir, "explicit.cer")}
+
+	for _, path := range files {
+		ca, leaf, err := NewCAAndLeafCert(WithEd25519())
+
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+
+		if err := os.WriteFile(path, CertificateToPEM(ca.Leaf), 0644); err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+		leaves = append(leaves, leaf)
+	}
+
+	// Neither is read from the directory
+	_, keyPEM, err := NewCertPEM(WithEd25519())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	os.WriteFile(filepath.Join(caDir, "key.pem"), keyPEM, 0600)
+	os.WriteFile(filepath.Join(caDir, "notes.txt"), []byte("not a certificate"), 0644)
+
+	pool, err := NewCertPoolFromFiles(caDir, files[2])
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	for i, leaf := range leaves {
+		if _, err := leaf.Leaf.Verify(x509.VerifyOptions{Roots: pool}); err != nil {
+			t.Errorf("Unexpected error verifying leaf %d: %v\n", i, err)
+		}
+	}
+
+	invalid := [][]string{
+		{filepath.Join(dir, "missing.pem")},
+		{filepath.Join(caDir, "key.pem")},
+		{t.TempDir()},
+	}
+
+	for _, paths := range invalid {
+		if _, err := NewCertPoolFromFiles(paths...); err == nil {
+			t.Errorf("Expected an error for %v\n", paths)
+		}
+	}
+}
+
+func TestNewCertPoolWithSystem(t *testing.T) {
+	ca, leaf, err := NewCAAndLeafCert(WithEd25519())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "ca.pem")
+
+	if err := os.WriteFile(path, CertificateToPEM(ca.Leaf), 0644); err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	pool, err := NewCertPoolWithSystem(path)
+
+	if err != nil {
+		t.Skipf("System roots unavailable: %v\n", err)
+	}
+
+	if _, err := leaf.Leaf.Verify(x509.VerifyOptions{Roots: pool}); err != nil {
+		t.Errorf("Unexpected error: %v\n", err)
+	}
+}
+
+func TestIndexedCertPool(t *testing.T) {
+	first, err := NewCert(WithEd25519(), WithCommonName("shared"))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	second, err := NewCert(WithEd25519(), WithCommonName("shared"))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	p := NewIndexedCertPool()
+
+	if !p.Add(first.Leaf) || !p.Add(second.Leaf) {
+		t.Error("Expected distinct certificates to be added")
+	}
+
+	// A duplicate parsed from another source
+	dup, err := x509.ParseCertificate(first.Leaf.Raw)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if p.Add(dup) {
+		t.Error("Expected a duplicate certificate to be rejected")
+	}
+
+	fp := FingerprintSHA256(first.Leaf)
+	plain := strings.ToLower(strings.ReplaceAll(fp, ":", ""))
+
+	for _, query := range []string{fp, plain} {
+		if got := p.FindByFingerprint(query); got != first.Leaf {
+			t.Errorf("Unexpected certificate for fingerprint %s\n", query)
+		}
+	}
+
+	if p.FindByFingerprint("AB:CD") != nil {
+		t.Error("Expected no certificate for an invalid fingerprint")
+	}
+
+	for _, name := range []string{"shared", first.Leaf.Subject.String()} {
+		if got := p.FindBySubject(name); len(got) != 2 || got[0] != first.Leaf || got[1] != second.Leaf {
+			t.Errorf("Unexpected certificates for subject %q: %d\n", name, len(got))
+		}
+	}
+
+	if _, err := first.Leaf.Verify(x509.VerifyOptions{Roots: p.CertPool(), DNSName: "localhost"}); err != nil {
+		t.Errorf("Unexpected error: %v\n", err)
+	}
+}
+
+func TestDeduplicateCertPool(t *testing.T) {
+	cert, err := NewCert(WithEd25519())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert.Leaf)
+	pool.AddCert(cert.Leaf)
+
+	deduplicated, removed, err := DeduplicateCertPool(pool)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if removed != 0 || !deduplicated.Equal(pool) {
+		t.Errorf("Unexpected deduplicated pool, %d removed\n", removed)
+	}
+
+	if _, _, err := DeduplicateCertPool(nil); err == nil {
+		t.Error("Expected an error for a nil pool")
+	}
+}
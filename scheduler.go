@@ -0,0 +1,247 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"container/heap"
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+)
+
+// RenewalScheduler renews the certificates of a CertStore before they expire.
+// Rather than polling every certificate, it keeps them in a queue ordered by
+// renewal time and sleeps until the first one is due, so each renewal costs
+// O(log n) in the number of certificates.
+type RenewalScheduler struct {
+	store       CertStore
+	renewBefore time.Duration
+	gen         func(name string) (tls.Certificate, error)
+	logger      *slog.Logger
+
+	mu    sync.Mutex
+	queue renewalQueue
+	items map[string]*renewalItem
+
+	wake      chan struct{}
+	done      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+}
+
+// NewRenewalScheduler creates a scheduler that regenerates the certificates
+// added with Add by calling gen with their name, every time they get within
+// renewBefore of their expiry, and saves them in the store. When a renewal
+// fails the stored certificate is kept and the renewal is retried a minute
+// later. See WithLogger to log the renewals.
+func NewRenewalScheduler(store CertStore, renewBefore time.Duration, gen func(name string) (tls.Certificate, error),
+	opts ...BackgroundOption) (*RenewalScheduler, error) {
+	if store == nil {
+		return nil, errors.New("privatetls: certificate store must not be nil")
+	}
+
+	if gen == nil {
+		return nil, errors.New("privatetls: certificate generator must not be nil")
+	}
+
+	if renewBefore < 0 {
+		return nil, errors.New("privatetls: renewal period must not be negative")
+	}
+
+	s := &RenewalScheduler{
+		store:       store,
+		renewBefore: renewBefore,
+		gen:         gen,
+		logger:      newBackgroundConfig(opts).logger,
+		items:       make(map[string]*renewalItem),
+		wake:        make(chan struct{}, 1),
+		done:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+
+	go s.run()
+
+	return s, nil
+}
+
+// Add schedules the renewal of the certificate saved under the name. If the
+// store has no certificate under the name, one is generated and stored
+// right away. Adding a name again reschedules it.
+func (s *RenewalScheduler) Add(name string) error {
+	cert, ok := s.store.Load(name)
+	if !ok {
+		var err error
+		if cert, err = s.generate(name); err != nil {
+			return err
+		}
+	}
+
+	leaf, err := leafCertificate(cert)
+	if err != nil {
+		return err
+	}
+
+	s.schedule(name, s.renewalTime(leaf.NotAfter))
+
+	return nil
+}
+
+// Stop cancels all the renewals and waits for the background goroutine to
+// exit. The stored certificates are left in place.
+func (s *RenewalScheduler) Stop() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+	<-s.stopped
+}
+
+// Renew the certificates as they fall due, until stopped
+func (s *RenewalScheduler) run() {
+	defer close(s.stopped)
+
+	for {
+		// With nothing queued, sleep until woken up by Add
+		wait := time.Duration(math.MaxInt64)
+
+		s.mu.Lock()
+		if len(s.queue) > 0 {
+			wait = time.Until(s.queue[0].renewAt)
+		}
+		s.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+
+		select {
+		case <-s.done:
+			timer.Stop()
+			return
+		case <-s.wake:
+			timer.Stop()
+			continue
+		case <-timer.C:
+		}
+
+		s.renewDue()
+	}
+}
+
+// Renew every certificate whose renewal time has passed
+func (s *RenewalScheduler) renewDue() {
+	for {
+		s.mu.Lock()
+		if len(s.queue) == 0 || time.Now().Before(s.queue[0].renewAt) {
+			s.mu.Unlock()
+			return
+		}
+		name := s.queue[0].name
+		s.mu.Unlock()
+
+		s.logger.Debug("privatetls: renewing certificate", "name", name)
+
+		next := time.Now().Add(renewRetryInterval)
+
+		cert, err := s.generate(name)
+		if err != nil {
+			s.logger.Error("privatetls: certificate renewal failed", "name", name, "error", err,
+				"retry_in", renewRetryInterval)
+		} else if leaf, err := leafCertificate(cert); err == nil {
+			// Never spin on a generator that returns short-lived certificates
+			if renewAt := s.renewalTime(leaf.NotAfter); renewAt.After(time.Now()) {
+				next = renewAt
+			}
+		}
+
+		s.schedule(name, next)
+	}
+}
+
+// Generate a certificate and save it in the store
+func (s *RenewalScheduler) generate(name string) (tls.Certificate, error) {
+	start := time.Now()
+	cert, err := s.gen(name)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	took := time.Since(start)
+
+	if err := s.store.Store(name, cert); err != nil {
+		return tls.Certificate{}, err
+	}
+
+	s.logger.Info("privatetls: certificate generated", "name", name, "took", took)
+
+	return cert, nil
+}
+
+// Insert or move the name in the queue, and wake up the background goroutine
+// in case it is now first
+func (s *RenewalScheduler) schedule(name string, renewAt time.Time) {
+	s.mu.Lock()
+	if item, ok := s.items[name]; ok {
+		item.renewAt = renewAt
+		heap.Fix(&s.queue, item.index)
+	} else {
+		item := &renewalItem{name: name, renewAt: renewAt}
+		s.items[name] = item
+		heap.Push(&s.queue, item)
+	}
+	s.mu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// When a certificate expiring at notAfter is due for renewal
+func (s *RenewalScheduler) renewalTime(notAfter time.Time) time.Time {
+	return notAfter.Add(-s.renewBefore)
+}
+
+// A certificate waiting for renewal, and its position in the queue
+type renewalItem struct {
+	name    string
+	renewAt time.Time
+	index   int
+}
+
+// A min-heap of certificates by renewal time, see container/heap
+type renewalQueue []*renewalItem
+
+func (q renewalQueue) Len() int { return len(q) }
+
+func (q renewalQueue) Less(i, j int) bool { return q[i].renewAt.Before(q[j].renewAt) }
+
+func (q renewalQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *renewalQueue) Push(x any) {
+	item := x.(*renewalItem)
+	item.index = len(*q)
+	*q = append(*q, item)
+}
+
+func (q *renewalQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+
+	return item
+}
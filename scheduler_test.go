@@ -0,0 +1,97 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"bytes"
+	"crypto/tls"
+	"testing"
+	"time"
+)
+
+func TestRenewalScheduler(t *testing.T) {
+	store := NewMemoryCertStore()
+	gen := func(name string) (tls.Certificate, error) {
+		return NewCert(WithEd25519(), WithCommonName(name), WithValidity(3*time.Second), WithNotBeforeOffset(0))
+	}
+
+	s, err := NewRenewalScheduler(store, 2*time.Second, gen)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	defer s.Stop()
+
+	long, err := NewCert(WithEd25519(), WithValidity(time.Hour))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if err := store.Store("long", long); err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	initial := make(map[string][]byte)
+	for _, name := range []string{"a", "b", "long"} {
+		if err := s.Add(name); err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+
+		cert, ok := store.Load(name)
+		if !ok {
+			t.Fatalf("Expected a certificate to be stored for %s\n", name)
+		}
+		initial[name] = cert.Certificate[0]
+	}
+
+	renewed := func(name string) bool {
+		cert, _ := store.Load(name)
+		return !bytes.Equal(cert.Certificate[0], initial[name])
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) && !(renewed("a") && renewed("b")) {
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if !renewed("a") || !renewed("b") {
+		t.Error("Expected the short-lived certificates to be renewed")
+	}
+
+	if renewed("long") {
+		t.Error("Expected the long-lived certificate to be kept")
+	}
+
+	s.Stop()
+	s.Stop()
+}
+
+func TestRenewalSchedulerInvalid(t *testing.T) {
+	gen := func(string) (tls.Certificate, error) {
+		return NewCert(WithEd25519())
+	}
+
+	if _, err := NewRenewalScheduler(nil, time.Hour, gen); err == nil {
+		t.Error("Expected an error for a nil store")
+	}
+
+	if _, err := NewRenewalScheduler(NewMemoryCertStore(), time.Hour, nil); err == nil {
+		t.Error("Expected an error for a nil generator")
+	}
+
+	if _, err := NewRenewalScheduler(NewMemoryCertStore(), -time.Hour, gen); err == nil {
+		t.Error("Expected an error for a negative renewal period")
+	}
+}
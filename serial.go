@@ -0,0 +1,86 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto/rand"
+	"io"
+	"math/big"
+	"sync"
+)
+
+// SerialRegistry hands out the serial numbers of issued certificates. RFC 5280
+// requires serial numbers to be unique per CA, and positive.
+type SerialRegistry interface {
+	Next() (*big.Int, error)
+}
+
+// WithSerialRegistry takes the certificate serial number from the registry,
+// e.g. a MonotonicSerial shared by all certificates issued by a CA. By default
+// the serial is random, see RandomSerial.
+func WithSerialRegistry(r SerialRegistry) Option {
+	return func(c *certConfig) {
+		c.serials = r
+	}
+}
+
+// RandomSerial returns a registry of random 128 bit serial numbers, which are
+// unique with overwhelming probability. This is the default.
+func RandomSerial() SerialRegistry {
+	return randomSerial{rand.Reader}
+}
+
+type randomSerial struct {
+	random io.Reader
+}
+
+// Next returns a random positive serial number
+func (s randomSerial) Next() (*big.Int, error) {
+	limit := new(big.Int).Lsh(big.NewInt(1), serialNumberBits)
+
+	for {
+		n, err := rand.Int(s.random, limit)
+		if err != nil || n.Sign() > 0 {
+			return n, err
+		}
+	}
+}
+
+// MonotonicSerial returns a registry counting up from start, or from 1 if
+// start is nil. It is safe for concurrent use, but does not persist: a CA
+// restarting from the same start reuses serial numbers.
+func MonotonicSerial(start *big.Int) SerialRegistry {
+	next := big.NewInt(1)
+	if start != nil {
+		next.Set(start)
+	}
+
+	return &monotonicSerial{next: next}
+}
+
+type monotonicSerial struct {
+	mu   sync.Mutex
+	next *big.Int
+}
+
+// Next returns the current serial number and increments the counter
+func (s *monotonicSerial) Next() (*big.Int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := new(big.Int).Set(s.next)
+	s.next.Add(s.next, big.NewInt(1))
+
+	return n, nil
+}
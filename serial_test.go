@@ -0,0 +1,73 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"math/big"
+	"sync"
+	"testing"
+)
+
+func TestMonotonicSerial(t *testing.T) {
+	serials := MonotonicSerial(big.NewInt(100))
+
+	for _, want := range []int64{100, 101} {
+		cert, err := NewCert(WithEd25519(), WithSerialRegistry(serials))
+
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+
+		if cert.Leaf.SerialNumber.Int64() != want {
+			t.Errorf("Expected serial %d, got %v\n", want, cert.Leaf.SerialNumber)
+		}
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		seen = make(map[string]bool)
+	)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n, _ := serials.Next()
+
+			mu.Lock()
+			defer mu.Unlock()
+			if seen[n.String()] {
+				t.Errorf("Duplicate serial %v\n", n)
+			}
+			seen[n.String()] = true
+		}()
+	}
+	wg.Wait()
+}
+
+func TestRandomSerial(t *testing.T) {
+	n, err := RandomSerial().Next()
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if n.Sign() <= 0 || n.BitLen() > serialNumberBits {
+		t.Errorf("Unexpected serial %v\n", n)
+	}
+
+	if _, err := NewCert(WithEd25519(), WithSerialRegistry(MonotonicSerial(big.NewInt(0)))); err == nil {
+		t.Error("Expected an error for a zero serial number")
+	}
+}
@@ -0,0 +1,492 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ServerOptions holds the timeouts of the HTTPS server, see http.Server for
+// their meaning. A zero value means no timeout, which matches the behavior of
+// StartHTTPSListener.
+//
+// Production servers should set at least ReadHeaderTimeout and WriteTimeout,
+// otherwise a slow or idle client can hold a connection open indefinitely.
+type ServerOptions struct {
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+}
+
+// Copy the timeouts to the server
+func (o ServerOptions) apply(s *http.Server) {
+	s.ReadTimeout = o.ReadTimeout
+	s.ReadHeaderTimeout = o.ReadHeaderTimeout
+	s.WriteTimeout = o.WriteTimeout
+	s.IdleTimeout = o.IdleTimeout
+}
+
+// ServerOption changes the server built by NewServer
+type ServerOption func(*serverConfig)
+
+// The resolved description of a server to build
+type serverConfig struct {
+	handler        http.Handler
+	timeouts       ServerOptions
+	certOptions    []Option
+	maxConnections int
+	connState      func(net.Conn, http.ConnState)
+}
+
+// WithHandler sets the handler serving the requests, by default
+// http.DefaultServeMux
+func WithHandler(h http.Handler) ServerOption {
+	return func(c *serverConfig) {
+		c.handler = h
+	}
+}
+
+// WithTimeouts sets the timeouts of the server
+func WithTimeouts(o ServerOptions) ServerOption {
+	return func(c *serverConfig) {
+		c.timeouts = o
+	}
+}
+
+// WithServerCertOptions adds options for the generated certificate, applied
+// after the SANs derived from the address, e.g. WithDNSNames to override them
+func WithServerCertOptions(opts ...Option) ServerOption {
+	return func(c *serverConfig) {
+		c.certOptions = append(c.certOptions, opts...)
+	}
+}
+
+// WithMaxConnections limits the connections the server holds open at once
+// to n, which bounds the memory taken by their TLS state. At the limit, the
+// server stops accepting connections until one closes, leaving new clients in
+// the listen backlog. Hijacked connections no longer count. On Shutdown, the
+// connections in progress complete, while those waiting are closed; Close
+// closes them all. A non-positive n means no limit.
+//
+// The limit is enforced by the ConnState hook of the server, so set a hook of
+// your own with WithConnState rather than on the returned server, which would
+// remove the limit.
+func WithMaxConnections(n int) ServerOption {
+	return func(c *serverConfig) {
+		c.maxConnections = n
+	}
+}
+
+// WithConnState sets the ConnState hook of the server, called on every
+// connection state change like http.Server.ConnState, after the limit of
+// WithMaxConnections has been applied
+func WithConnState(fn func(net.Conn, http.ConnState)) ServerOption {
+	return func(c *serverConfig) {
+		c.connState = fn
+	}
+}
+
+// A counting semaphore over the connections of a server, driven by its
+// ConnState hook. http.Server calls the hook for StateNew from its accept
+// loop, before serving the connection, so blocking there holds back the next
+// Accept; TestWithMaxConnections guards that behavior.
+type connLimiter struct {
+	slots chan struct{}
+	conns sync.Map // The connections holding a slot
+	next  func(net.Conn, http.ConnState)
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Limit the connections of the server to n, calling its ConnState hook, if
+// any, after the limiter
+func limitConnections(s *http.Server, n int) {
+	l := &connLimiter{
+		slots: make(chan struct{}, n),
+		next:  s.ConnState,
+		done:  make(chan struct{}),
+	}
+
+	s.ConnState = l.connState
+	s.RegisterOnShutdown(func() {
+		l.closeOnce.Do(func() {
+			close(l.done)
+		})
+	})
+}
+
+// Take a slot for a new connection, blocking the accept loop of the server
+// until one is free, and release it when the connection is done
+func (l *connLimiter) connState(c net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateNew:
+		select {
+		case l.slots <- struct{}{}:
+			l.conns.Store(c, struct{}{})
+		case <-l.done:
+			c.Close()
+		}
+	case http.StateHijacked, http.StateClosed:
+		if _, ok := l.conns.LoadAndDelete(c); ok {
+			<-l.slots
+		}
+	}
+
+	if l.next != nil {
+		l.next(c, state)
+	}
+}
+
+// StartHTTPSListener starts an HTTPS server at the address specified
+// by the service parameter using self-signed TLS certificate. If blank,
+// the default value of ":https" is used. The listener will use a self-signed
+// RSA based TLS certificate with a random 2048 bit key.
+// The certificate is valid for 1 year. Requests are served by
+// http.DefaultServeMux; use StartHTTPSListenerWithHandler to supply a handler.
+func StartHTTPSListener(service string) error {
+	return StartHTTPSListenerWithHandler(service, nil)
+}
+
+// StartHTTPSListenerWithHandler starts an HTTPS server like StartHTTPSListener,
+// serving requests with the supplied handler. A nil handler falls back to
+// http.DefaultServeMux.
+func StartHTTPSListenerWithHandler(service string, handler http.Handler) error {
+	return StartHTTPSListenerWithOptions(service, handler, ServerOptions{})
+}
+
+// StartHTTPSListenerWithOptions starts an HTTPS server like
+// StartHTTPSListenerWithHandler, using the timeouts set in opts.
+func StartHTTPSListenerWithOptions(service string, handler http.Handler, opts ServerOptions) error {
+	s, _, err := NewServer(service, WithHandler(handler), WithTimeouts(opts))
+
+	if err != nil {
+		return err
+	}
+
+	return s.ListenAndServeTLS("", "")
+}
+
+// StartHTTPSListenerTLS13 starts an HTTPS server like StartHTTPSListener that
+// only accepts TLS 1.3 connections
+func StartHTTPSListenerTLS13(service string) error {
+	s, err := NewHTTPSServer(service)
+
+	if err != nil {
+		return err
+	}
+
+	if err := WithTLS13Only()(s.TLSConfig); err != nil {
+		return err
+	}
+
+	return s.ListenAndServeTLS("", "")
+}
+
+// How long StartHTTPSListenerContext waits for active requests on shutdown
+const shutdownTimeout = 10 * time.Second
+
+// StartHTTPSListenerContext starts an HTTPS server like StartHTTPSListener,
+// and shuts it down when ctx is done. Active requests get up to 10 seconds to
+// complete before their connections are closed. It returns nil after a clean
+// shutdown, or the error that stopped the server otherwise.
+func StartHTTPSListenerContext(ctx context.Context, service string) error {
+	s, err := NewHTTPSServer(service)
+
+	if err != nil {
+		return err
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.ListenAndServeTLS("", "")
+	}()
+
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		// ctx is already done, so drain with a fresh deadline. Close drops the
+		// connections still active after it.
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+		s.Shutdown(shutdownCtx)
+		cancel()
+		s.Close()
+		err = <-errCh
+	}
+
+	if err == http.ErrServerClosed {
+		return nil
+	}
+
+	return err
+}
+
+// StartHTTPSUnixListener starts an HTTPS server on a Unix domain socket at
+// socketPath, with a self-signed certificate for localhost and the file://
+// URI of the socket, and serves requests with the handler. A socket file left
+// behind by a server that is no longer running is removed first. When serving
+// over a Unix socket, clients can be authenticated by their socket
+// credentials, so the server uses tls.NoClientCert; to require client
+// certificates instead, serve the socket with an http.Server whose TLSConfig
+// is built with WithClientAuth. It blocks like StartHTTPSListener and returns
+// the error that stopped the server, after removing the socket file.
+func StartHTTPSUnixListener(socketPath string, handler http.Handler) error {
+	abs, err := filepath.Abs(socketPath)
+	if err != nil {
+		return err
+	}
+
+	cert, err := NewCert(WithURISANs(&url.URL{Scheme: "file", Path: abs}))
+	if err != nil {
+		return err
+	}
+
+	if err := removeStaleSocket(socketPath); err != nil {
+		return err
+	}
+
+	// Closing a Unix listener removes the socket file, and Serve closes it on return
+	l, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return err
+	}
+
+	s := &http.Server{Handler: handler, TLSConfig: &tls.Config{Certificates: []tls.Certificate{cert}}}
+
+	return s.ServeTLS(l, "", "")
+}
+
+// Remove the socket file at the path unless a server still accepts
+// connections on it. Other files are left for net.Listen to fail on.
+func removeStaleSocket(socketPath string) error {
+	fi, err := os.Lstat(socketPath)
+	if err != nil || fi.Mode()&os.ModeSocket == 0 {
+		return nil
+	}
+
+	if conn, err := net.Dial("unix", socketPath); err == nil {
+		conn.Close()
+		return fmt.Errorf("privatetls: socket %s is in use", socketPath)
+	}
+
+	return os.Remove(socketPath)
+}
+
+// How long the redirect server waits on slow clients
+const redirectTimeout = 5 * time.Second
+
+// StartHTTPRedirectListener starts a plain HTTP server at httpAddr that
+// redirects every request to the same host, path and query over HTTPS on
+// the port of httpsAddr, with 301 Moved Permanently. Run it next to
+// StartHTTPSListener so that http://localhost upgrades to https://localhost.
+func StartHTTPRedirectListener(httpAddr, httpsAddr string) error {
+	s := &http.Server{
+		Addr:              httpAddr,
+		Handler:           redirectHandler(httpsAddr),
+		ReadHeaderTimeout: redirectTimeout,
+		WriteTimeout:      redirectTimeout,
+	}
+
+	return s.ListenAndServe()
+}
+
+// Redirect requests to the HTTPS port of httpsAddr, leaving the default port implicit
+func redirectHandler(httpsAddr string) http.Handler {
+	_, port, err := net.SplitHostPort(httpsAddr)
+	if err != nil || port == "443" || port == "https" {
+		port = ""
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := strings.TrimSuffix(strings.TrimPrefix(r.Host, "["), "]")
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+
+		if port != "" {
+			host = net.JoinHostPort(host, port)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
+
+// The ALPN protocols offered by the servers, like http.ListenAndServeTLS
+var defaultNextProtos = []string{"h2", "http/1.1"}
+
+// NewServer creates, but does not start, an HTTPS server for the address
+// specified by the service parameter, configured with a self-signed TLS
+// certificate generated by NewCert for the names in AutoSANFromAddress. The
+// server negotiates HTTP/2 or HTTP/1.1 with ALPN. The certificate is returned
+// too, e.g. for clients to trust it. Callers can
+// register handlers or shutdown hooks, then start the server with
+// ListenAndServeTLS("", "") and stop it with Shutdown or Close.
+func NewServer(service string, opts ...ServerOption) (*http.Server, tls.Certificate, error) {
+	cfg := serverConfig{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	selfSignedCert, err := NewCert(append(AutoSANFromAddress(service), cfg.certOptions...)...)
+
+	if err != nil {
+		return nil, tls.Certificate{}, err
+	}
+
+	s := &http.Server{
+		Addr:    service,
+		Handler: cfg.handler,
+		TLSConfig: &tls.Config{
+			Certificates: []tls.Certificate{selfSignedCert},
+			NextProtos:   append([]string(nil), defaultNextProtos...),
+		},
+		ConnState: cfg.connState,
+	}
+	cfg.timeouts.apply(s)
+
+	if cfg.maxConnections > 0 {
+		limitConnections(s, cfg.maxConnections)
+	}
+
+	return s, selfSignedCert, nil
+}
+
+// NewSharedCertServer starts one HTTPS server per address, e.g. for ports 443
+// and 8443 or for several interfaces, all presenting the same certificates and
+// serving requests with the handler. It returns once every address is
+// listening, or fails without starting any server if one cannot be listened
+// on. The Addr of each server is the address it listens on, e.g. with the port
+// picked for ":0". Stop the servers individually or together with Shutdown or
+// Close; errors of the running servers are not reported otherwise.
+func NewSharedCertServer(certs []tls.Certificate, addrs []string, handler http.Handler) ([]*http.Server, error) {
+	if len(certs) == 0 {
+		return nil, errors.New("privatetls: at least one certificate is required")
+	}
+
+	if len(addrs) == 0 {
+		return nil, errors.New("privatetls: at least one address is required")
+	}
+
+	listeners := make([]net.Listener, 0, len(addrs))
+	for _, addr := range addrs {
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, l)
+	}
+
+	servers := make([]*http.Server, len(listeners))
+	for i, l := range listeners {
+		servers[i] = &http.Server{
+			Addr:    l.Addr().String(),
+			Handler: handler,
+			TLSConfig: &tls.Config{
+				Certificates: certs,
+				NextProtos:   append([]string(nil), defaultNextProtos...),
+			},
+		}
+		go servers[i].ServeTLS(l, "", "")
+	}
+
+	return servers, nil
+}
+
+// NewHTTPSServer creates, but does not start, an HTTPS server like NewServer
+// with the default options. The certificate is in the Certificates of the
+// server TLSConfig.
+func NewHTTPSServer(service string) (*http.Server, error) {
+	s, _, err := NewServer(service)
+
+	return s, err
+}
+
+// AutoSANFromAddress returns the SAN options matching a listen address such as
+// ":8443" or "10.0.0.5:443", or a host without a port such as "[::1]". An
+// address on all interfaces, or on the host name localhost, keeps the default
+// SANs localhost, 127.0.0.1 and ::1, so that local clients can connect by any
+// of these names. An address bound to a specific IP or host name gets a
+// certificate for just that name.
+func AutoSANFromAddress(addr string) []Option {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		// No port, the host may still be a bracketed IPv6 address
+		host = strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+	}
+
+	if host == "" || host == "localhost" {
+		return nil
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		if ip.IsUnspecified() {
+			return nil
+		}
+		return []Option{WithIPAddresses(ip), WithDNSNames()}
+	}
+
+	return []Option{WithDNSNames(host), WithIPAddresses()}
+}
+
+// NewSNIServer creates, but does not start, an HTTPS server that selects the
+// certificate by the SNI name sent by the client. The map keys are the server
+// names; they are matched case-insensitively and ignoring a trailing dot. When
+// the client sends no name, or one not in the map, the certificate of the
+// alphabetically first name is used. Set the address of the returned server
+// before starting it.
+func NewSNIServer(addrs map[string]tls.Certificate, handler http.Handler) (*http.Server, error) {
+	if len(addrs) == 0 {
+		return nil, errors.New("privatetls: at least one certificate is required")
+	}
+
+	store := NewMemoryCertStore()
+	names := make([]string, 0, len(addrs))
+
+	for name, cert := range addrs {
+		key := normalizeServerName(name)
+		if _, ok := store.Load(key); ok {
+			return nil, fmt.Errorf("privatetls: duplicate server name %q", name)
+		}
+
+		store.Store(key, cert)
+		names = append(names, key)
+	}
+
+	sort.Strings(names)
+
+	s := &http.Server{
+		Handler:   handler,
+		TLSConfig: TLSConfigFromStore(store, names[0]),
+	}
+
+	return s, nil
+}
@@ -0,0 +1,659 @@
+// Copyright © 2017 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewHTTPSServer(t *testing.T) {
+	s, err := NewHTTPSServer("127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "Hello from PrivateTLS!")
+	})
+
+	l, err := net.Listen("tcp", s.Addr)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	go s.ServeTLS(l, "", "")
+	defer s.Shutdown(context.Background())
+
+	body := httpsGet(t, s.TLSConfig.Certificates[0], "https://"+l.Addr().String())
+
+	if body != "Hello from PrivateTLS!" {
+		t.Errorf("Unexpected response: %q\n", body)
+	}
+}
+
+func TestStartHTTPSListenerContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- StartHTTPSListenerContext(ctx, "127.0.0.1:0")
+	}()
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Errorf("Unexpected error: %v\n", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Server did not shut down after the context was done")
+	}
+}
+
+func TestStartHTTPSListenerContextDrain(t *testing.T) {
+	// The server uses http.DefaultServeMux, so register a path unique to the run
+	path := fmt.Sprintf("/drain-test-%d", time.Now().UnixNano())
+	started := make(chan struct{})
+	http.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(300 * time.Millisecond)
+		fmt.Fprint(w, "drained")
+	})
+
+	addr := freeAddr(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- StartHTTPSListenerContext(ctx, addr)
+	}()
+
+	waitForListener(t, addr)
+
+	type result struct {
+		body string
+		err  error
+	}
+	resultCh := make(chan result, 1)
+	go func() {
+		client := http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+		resp, err := client.Get("https://" + addr + path)
+		if err != nil {
+			resultCh <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		resultCh <- result{string(body), err}
+	}()
+
+	<-started
+	cancel()
+
+	// The request in flight completes before the server stops
+	if r := <-resultCh; r.err != nil || r.body != "drained" {
+		t.Errorf("Expected the active request to complete, got %q: %v\n", r.body, r.err)
+	}
+
+	if err := <-errCh; err != nil {
+		t.Errorf("Unexpected error: %v\n", err)
+	}
+}
+
+func TestStartHTTPSListenerContextError(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	defer l.Close()
+
+	// The address is already in use, so the server must fail to start
+	if err := StartHTTPSListenerContext(context.Background(), l.Addr().String()); err == nil {
+		t.Error("Expected an error for an address in use")
+	}
+}
+
+func TestStartHTTPSListenerWithHandler(t *testing.T) {
+	addr := freeAddr(t)
+
+	go StartHTTPSListenerWithHandler(addr, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "Hello from a custom handler!")
+	}))
+
+	waitForListener(t, addr)
+
+	// The certificate is not known to the test, so only the response is verified
+	client := http.Client{
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+	}
+
+	resp, err := client.Get("https://" + addr)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	if string(body) != "Hello from a custom handler!" {
+		t.Errorf("Unexpected response: %q\n", body)
+	}
+}
+
+func TestServerOptions(t *testing.T) {
+	opts := ServerOptions{
+		ReadTimeout:       time.Second,
+		ReadHeaderTimeout: 2 * time.Second,
+		WriteTimeout:      3 * time.Second,
+		IdleTimeout:       4 * time.Second,
+	}
+
+	s := http.Server{}
+	opts.apply(&s)
+
+	if s.ReadTimeout != opts.ReadTimeout || s.ReadHeaderTimeout != opts.ReadHeaderTimeout ||
+		s.WriteTimeout != opts.WriteTimeout || s.IdleTimeout != opts.IdleTimeout {
+		t.Errorf("Unexpected server timeouts: %v %v %v %v\n", s.ReadTimeout, s.ReadHeaderTimeout, s.WriteTimeout, s.IdleTimeout)
+	}
+}
+
+func TestNewSNIServer(t *testing.T) {
+	apiCert, err := NewCert(WithEd25519(), WithDNSNames("api.internal"))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	webCert, err := NewCert(WithEd25519(), WithDNSNames("web.internal"))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	s, err := NewSNIServer(map[string]tls.Certificate{"API.internal": apiCert, "web.internal.": webCert}, nil)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	tests := []struct {
+		serverName string
+		want       tls.Certificate
+	}{
+		{"api.internal", apiCert},
+		{"Web.Internal.", webCert},
+		{"unknown.internal", apiCert},
+		{"", apiCert},
+	}
+
+	for _, tt := range tests {
+		got, err := s.TLSConfig.GetCertificate(&tls.ClientHelloInfo{ServerName: tt.serverName})
+
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+
+		if !bytes.Equal(got.Certificate[0], tt.want.Certificate[0]) {
+			t.Errorf("Unexpected certificate selected for %q\n", tt.serverName)
+		}
+	}
+
+	if _, err := NewSNIServer(nil, nil); err == nil {
+		t.Error("Expected an error for no certificates")
+	}
+
+	if _, err := NewSNIServer(map[string]tls.Certificate{"api.internal": apiCert, "API.internal.": webCert}, nil); err == nil {
+		t.Error("Expected an error for duplicate server names")
+	}
+}
+
+// Find a local address that is currently not in use
+func freeAddr(t *testing.T) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	defer l.Close()
+
+	return l.Addr().String()
+}
+
+// Wait until a server started in the background accepts connections
+func waitForListener(t *testing.T, addr string) {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if conn, err := net.Dial("tcp", addr); err == nil {
+			conn.Close()
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	t.Fatalf("Server at %s did not start\n", addr)
+}
+
+// Fetch a URL, trusting only the supplied self-signed certificate
+func httpsGet(t *testing.T, cert tls.Certificate, url string) string {
+	t.Helper()
+
+	x509Cert, err := x509.ParseCertificate(cert.Certificate[0])
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(x509Cert)
+
+	client := http.Client{
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}},
+	}
+
+	resp, err := client.Get(url)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	return string(body)
+}
+
+func TestAutoSANFromAddress(t *testing.T) {
+	tests := []struct {
+		addr string
+		dns  []string
+		ips  []string
+	}{
+		{":8443", []string{"localhost"}, []string{"127.0.0.1", "::1"}},
+		{"0.0.0.0:8443", []string{"localhost"}, []string{"127.0.0.1", "::1"}},
+		{"localhost:8443", []string{"localhost"}, []string{"127.0.0.1", "::1"}},
+		{"10.1.2.3:8443", nil, []string{"10.1.2.3"}},
+		{"[fd00::1]:8443", nil, []string{"fd00::1"}},
+		{"[::1]", nil, []string{"::1"}},
+		{"[::1]:443", nil, []string{"::1"}},
+		{"::1", nil, []string{"::1"}},
+		{"svc.internal", []string{"svc.internal"}, nil},
+		{"svc.internal:443", []string{"svc.internal"}, nil},
+	}
+
+	for _, tt := range tests {
+		cert, err := NewCert(append([]Option{WithEd25519()}, AutoSANFromAddress(tt.addr)...)...)
+
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+
+		if fmt.Sprint(cert.Leaf.DNSNames) != fmt.Sprint(tt.dns) || fmt.Sprint(cert.Leaf.IPAddresses) != fmt.Sprint(tt.ips) {
+			t.Errorf("%s: unexpected SANs %v %v\n", tt.addr, cert.Leaf.DNSNames, cert.Leaf.IPAddresses)
+		}
+	}
+}
+
+func TestNewServer(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "Hello from NewServer!")
+	})
+
+	s, cert, err := NewServer("127.0.0.1:0", WithHandler(handler),
+		WithTimeouts(ServerOptions{ReadHeaderTimeout: time.Second}), WithServerCertOptions(WithEd25519()))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if s.ReadHeaderTimeout != time.Second {
+		t.Errorf("Unexpected read header timeout: %v\n", s.ReadHeaderTimeout)
+	}
+
+	l, err := net.Listen("tcp", s.Addr)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	go s.ServeTLS(l, "", "")
+	defer s.Shutdown(context.Background())
+
+	if body := httpsGet(t, cert, "https://"+l.Addr().String()); body != "Hello from NewServer!" {
+		t.Errorf("Unexpected response: %q\n", body)
+	}
+}
+
+func TestNewServerHTTP2(t *testing.T) {
+	s, cert, err := NewServer("127.0.0.1:0", WithServerCertOptions(WithEd25519()))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	l, err := net.Listen("tcp", s.Addr)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	go s.ServeTLS(l, "", "")
+	defer s.Shutdown(context.Background())
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert.Leaf)
+
+	conn, err := tls.Dial("tcp", l.Addr().String(), &tls.Config{RootCAs: roots, NextProtos: []string{"h2", "http/1.1"}})
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	defer conn.Close()
+
+	if proto := conn.ConnectionState().NegotiatedProtocol; proto != "h2" {
+		t.Errorf("Unexpected negotiated protocol: %q\n", proto)
+	}
+}
+
+func TestWithMaxConnections(t *testing.T) {
+	s, cert, err := NewServer("127.0.0.1:0", WithMaxConnections(1), WithServerCertOptions(WithEd25519()))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	l, err := net.Listen("tcp", s.Addr)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	go s.ServeTLS(l, "", "")
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert.Leaf)
+	cfg := &tls.Config{RootCAs: roots}
+
+	first, err := tls.Dial("tcp", l.Addr().String(), cfg)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	// The second connection waits for the first in the listen backlog
+	dialed := make(chan error, 1)
+	go func() {
+		conn, err := tls.Dial("tcp", l.Addr().String(), cfg)
+		if err == nil {
+			conn.Close()
+		}
+		dialed <- err
+	}()
+
+	select {
+	case <-dialed:
+		t.Fatal("Expected the second connection to wait")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	first.Close()
+
+	select {
+	case err := <-dialed:
+		if err != nil {
+			t.Errorf("Unexpected error: %v\n", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Second connection was not accepted")
+	}
+
+	// Shutdown releases the waiting connections
+	held, err := tls.Dial("tcp", l.Addr().String(), cfg)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	defer held.Close()
+
+	go func() {
+		conn, err := tls.Dial("tcp", l.Addr().String(), cfg)
+		if err == nil {
+			conn.Close()
+		}
+		dialed <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	s.Shutdown(ctx)
+
+	select {
+	case err := <-dialed:
+		if err == nil {
+			t.Error("Expected the waiting connection to be closed on shutdown")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Waiting connection was not released on shutdown")
+	}
+}
+
+func TestWithMaxConnectionsAndConnState(t *testing.T) {
+	for _, limitFirst := range []bool{true, false} {
+		states := make(chan http.ConnState, 10)
+		opts := []ServerOption{WithMaxConnections(1), WithConnState(func(c net.Conn, state http.ConnState) {
+			states <- state
+		})}
+		if !limitFirst {
+			opts[0], opts[1] = opts[1], opts[0]
+		}
+
+		s, cert, err := NewServer("127.0.0.1:0", append(opts, WithServerCertOptions(WithEd25519()))...)
+
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+
+		l, err := net.Listen("tcp", s.Addr)
+
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+
+		go s.ServeTLS(l, "", "")
+
+		roots := x509.NewCertPool()
+		roots.AddCert(cert.Leaf)
+		cfg := &tls.Config{RootCAs: roots}
+
+		first, err := tls.Dial("tcp", l.Addr().String(), cfg)
+
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+
+		if state := <-states; state != http.StateNew {
+			t.Errorf("Unexpected connection state: %v\n", state)
+		}
+
+		// The limit still holds with the hook set
+		dialed := make(chan error, 1)
+		go func() {
+			conn, err := tls.Dial("tcp", l.Addr().String(), cfg)
+			if err == nil {
+				conn.Close()
+			}
+			dialed <- err
+		}()
+
+		select {
+		case <-dialed:
+			t.Error("Expected the second connection to wait")
+		case <-time.After(200 * time.Millisecond):
+		}
+
+		first.Close()
+		<-dialed
+		s.Close()
+	}
+}
+
+func TestNewSharedCertServer(t *testing.T) {
+	cert, err := NewCert(WithEd25519())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "Hello from a shared certificate!")
+	})
+
+	servers, err := NewSharedCertServer([]tls.Certificate{cert}, []string{"127.0.0.1:0", "127.0.0.1:0"}, handler)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if len(servers) != 2 || servers[0].Addr == servers[1].Addr {
+		t.Fatalf("Unexpected servers: %d\n", len(servers))
+	}
+
+	for _, s := range servers {
+		defer s.Close()
+
+		if body := httpsGet(t, cert, "https://"+s.Addr); body != "Hello from a shared certificate!" {
+			t.Errorf("Unexpected response from %s: %q\n", s.Addr, body)
+		}
+	}
+
+	// A busy address fails without starting any server
+	if _, err := NewSharedCertServer([]tls.Certificate{cert}, []string{"127.0.0.1:0", servers[0].Addr}, handler); err == nil {
+		t.Error("Expected an error for an address in use")
+	}
+
+	if _, err := NewSharedCertServer(nil, []string{"127.0.0.1:0"}, handler); err == nil {
+		t.Error("Expected an error without certificates")
+	}
+}
+
+func TestStartHTTPSUnixListener(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "https.sock")
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "Hello over a Unix socket!")
+	})
+
+	// A socket left behind by a server that is gone
+	stale, err := net.Listen("unix", socketPath)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	served := make(chan error, 1)
+	go func() {
+		served <- StartHTTPSUnixListener(socketPath, handler)
+	}()
+
+	var peer *x509.Certificate
+	client := http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+		},
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: true,
+			VerifyConnection: func(cs tls.ConnectionState) error {
+				peer = cs.PeerCertificates[0]
+				return nil
+			},
+		},
+	}}
+
+	deadline := time.Now().Add(10 * time.Second)
+	resp, err := client.Get("https://localhost/")
+	for err != nil && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+		resp, err = client.Get("https://localhost/")
+	}
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	defer resp.Body.Close()
+
+	if body, _ := ioutil.ReadAll(resp.Body); string(body) != "Hello over a Unix socket!" {
+		t.Errorf("Unexpected response: %q\n", body)
+	}
+
+	if len(peer.URIs) != 1 || peer.URIs[0].String() != "file://"+socketPath {
+		t.Errorf("Unexpected URI SANs: %v\n", peer.URIs)
+	}
+
+	// The socket of the running server is not taken over
+	if err := StartHTTPSUnixListener(socketPath, handler); err == nil {
+		t.Error("Expected an error for a socket in use")
+	}
+
+	select {
+	case err := <-served:
+		t.Errorf("Unexpected end of the server: %v\n", err)
+	default:
+	}
+}
+
+func TestRedirectHandler(t *testing.T) {
+	tests := []struct {
+		httpsAddr, url, want string
+	}{
+		{":8443", "http://localhost:8080/path?q=1", "https://localhost:8443/path?q=1"},
+		{":443", "http://localhost/", "https://localhost/"},
+		{"127.0.0.1:8443", "http://[::1]:8080/a", "https://[::1]:8443/a"},
+		{":https", "http://[::1]/a", "https://[::1]/a"},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		redirectHandler(tt.httpsAddr).ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+
+		if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != tt.want {
+			t.Errorf("%s: expected a redirect to %s, got %d %s\n", tt.url, tt.want, w.Code, w.Header().Get("Location"))
+		}
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/mail"
+	"strings"
+)
+
+// NewSMIMECert Generates a self-signed S/MIME certificate for the email
+// address, for signing and encrypting email in clients such as Thunderbird,
+// Apple Mail or Outlook. The certificate is valid for email protection only,
+// for digital signatures and non-repudiation, and for key encipherment when
+// the key is RSA. Its only SAN is the email address, and unless set by the
+// options its common name is the local part of the address.
+func NewSMIMECert(email string, opts ...Option) (tls.Certificate, error) {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return tls.Certificate{}, fmt.Errorf("privatetls: invalid email address %q", email)
+	}
+
+	cfg := withoutSANs(defaultCertConfig())
+	cfg.emailAddresses = []string{email}
+	cfg.commonName, _, _ = strings.Cut(email, "@")
+
+	return issueCert(applyOptions(cfg, opts), setSMIMEAttributes, nil, nil)
+}
+
+// Mark the template as an end-entity certificate for email protection
+func setSMIMEAttributes(t *x509.Certificate, pub crypto.PublicKey) {
+	t.IsCA = false
+	t.KeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment
+	t.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection}
+
+	// Only RSA keys can encrypt the content encryption key
+	if _, ok := pub.(*rsa.PublicKey); ok {
+		t.KeyUsage |= x509.KeyUsageKeyEncipherment
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto/x509"
+	"testing"
+)
+
+func TestNewSMIMECert(t *testing.T) {
+	cert, err := NewSMIMECert("alice@example.com", WithRSAKeyBits(1024))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	leaf := cert.Leaf
+	wantUsage := x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment | x509.KeyUsageKeyEncipherment
+
+	if leaf.KeyUsage != wantUsage || len(leaf.ExtKeyUsage) != 1 || leaf.ExtKeyUsage[0] != x509.ExtKeyUsageEmailProtection {
+		t.Errorf("Unexpected key usages: %v %v\n", leaf.KeyUsage, leaf.ExtKeyUsage)
+	}
+
+	if leaf.Subject.CommonName != "alice" || len(leaf.EmailAddresses) != 1 || leaf.EmailAddresses[0] != "alice@example.com" {
+		t.Errorf("Unexpected subject or email addresses: %v %v\n", leaf.Subject, leaf.EmailAddresses)
+	}
+
+	if len(leaf.DNSNames) != 0 || len(leaf.IPAddresses) != 0 || leaf.IsCA {
+		t.Errorf("Unexpected SANs or CA flag: %v %v %v\n", leaf.DNSNames, leaf.IPAddresses, leaf.IsCA)
+	}
+
+	named, err := NewSMIMECert("bob@example.com", WithEd25519(), WithCommonName("Bob"))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if named.Leaf.Subject.CommonName != "Bob" || named.Leaf.KeyUsage&x509.KeyUsageKeyEncipherment != 0 {
+		t.Errorf("Unexpected common name or key usage: %q %v\n", named.Leaf.Subject.CommonName, named.Leaf.KeyUsage)
+	}
+
+	for _, email := range []string{"", "not an address", "Alice <alice@example.com>"} {
+		if _, err := NewSMIMECert(email, WithEd25519()); err == nil {
+			t.Errorf("Expected an error for %q\n", email)
+		}
+	}
+}
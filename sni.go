@@ -0,0 +1,87 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+)
+
+// NewSNIFilter wraps a handler so that it only serves requests received over
+// TLS connections for one of the allowed server names, as sent by the client
+// with SNI. Other requests, including those over plain HTTP or without a
+// server name, are answered with 421 Misdirected Request. Names are matched
+// case-insensitively and ignoring a trailing dot. See WithSNIAllowList to
+// reject such connections during the handshake instead.
+func NewSNIFilter(allowed []string, next http.Handler) http.Handler {
+	names := serverNameSet(allowed)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || !names[normalizeServerName(r.TLS.ServerName)] {
+			http.Error(w, http.StatusText(http.StatusMisdirectedRequest), http.StatusMisdirectedRequest)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// WithSNIAllowList aborts the handshake with a TLS alert, before the server
+// presents its certificate, unless the client sends one of the allowed server
+// names with SNI. Clients that send no name, e.g. because they connect by IP
+// address, are rejected too. Names are matched like NewSNIFilter does. The
+// filter runs before the per-client configuration of WithPerClientConfig,
+// whichever of the two options is applied first.
+func WithSNIAllowList(allowed []string) TLSOption {
+	return sniFilter(allowed, true)
+}
+
+// WithSNIDenyList aborts the handshake like WithSNIAllowList when the client
+// sends one of the denied server names, accepting any other name, or none.
+func WithSNIDenyList(denied []string) TLSOption {
+	return sniFilter(denied, false)
+}
+
+// Filter the client hellos by server name, accepting either only the listed
+// names or all but them, before selecting the per-client configuration
+func sniFilter(list []string, allow bool) TLSOption {
+	names := serverNameSet(list)
+
+	return func(c *tls.Config) error {
+		next := c.GetConfigForClient
+
+		c.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			if names[normalizeServerName(hello.ServerName)] != allow {
+				return nil, fmt.Errorf("privatetls: server name %q is not allowed", hello.ServerName)
+			}
+
+			if next != nil {
+				return next(hello)
+			}
+			return nil, nil
+		}
+		return nil
+	}
+}
+
+// Index the normalized server names
+func serverNameSet(list []string) map[string]bool {
+	names := make(map[string]bool, len(list))
+	for _, name := range list {
+		names[normalizeServerName(name)] = true
+	}
+
+	return names
+}
@@ -0,0 +1,116 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewSNIFilter(t *testing.T) {
+	h := NewSNIFilter([]string{"api.internal"}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		state *tls.ConnectionState
+		want  int
+	}{
+		{&tls.ConnectionState{ServerName: "API.internal."}, http.StatusOK},
+		{&tls.ConnectionState{ServerName: "other.internal"}, http.StatusMisdirectedRequest},
+		{&tls.ConnectionState{}, http.StatusMisdirectedRequest},
+		{nil, http.StatusMisdirectedRequest},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.TLS = test.state
+		w := httptest.NewRecorder()
+
+		if h.ServeHTTP(w, r); w.Code != test.want {
+			t.Errorf("Unexpected status for %+v: %d\n", test.state, w.Code)
+		}
+	}
+}
+
+func TestWithSNIAllowList(t *testing.T) {
+	cfg, err := NewTLSConfig(WithSNIAllowList([]string{"api.internal"}))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if err := handshake(cfg, &tls.Config{InsecureSkipVerify: true, ServerName: "api.internal"}); err != nil {
+		t.Errorf("Unexpected error: %v\n", err)
+	}
+
+	for _, name := range []string{"other.internal", ""} {
+		if err := handshake(cfg, &tls.Config{InsecureSkipVerify: true, ServerName: name}); err == nil {
+			t.Errorf("Expected the handshake for %q to fail\n", name)
+		}
+	}
+}
+
+func TestWithSNIDenyList(t *testing.T) {
+	cfg, err := NewTLSConfig(WithSNIDenyList([]string{"legacy.internal"}))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if err := handshake(cfg, &tls.Config{InsecureSkipVerify: true, ServerName: "legacy.internal"}); err == nil {
+		t.Error("Expected the handshake for a denied name to fail")
+	}
+
+	for _, name := range []string{"api.internal", ""} {
+		if err := handshake(cfg, &tls.Config{InsecureSkipVerify: true, ServerName: name}); err != nil {
+			t.Errorf("Unexpected error for %q: %v\n", name, err)
+		}
+	}
+}
+
+func TestWithSNIAllowListAndPerClientConfig(t *testing.T) {
+	var called bool
+	perClient := WithPerClientConfig(func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		called = true
+		return nil, nil
+	})
+
+	allow := WithSNIAllowList([]string{"api.internal"})
+
+	for _, opts := range [][]TLSOption{{allow, perClient}, {perClient, allow}} {
+		cfg, err := NewTLSConfig(opts...)
+
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+
+		called = false
+		if err := handshake(cfg, &tls.Config{InsecureSkipVerify: true, ServerName: "other.internal"}); err == nil {
+			t.Error("Expected the handshake for a name not in the list to fail")
+		}
+
+		if called {
+			t.Error("Expected the per-client configuration not to run for a rejected name")
+		}
+
+		if err := handshake(cfg, &tls.Config{InsecureSkipVerify: true, ServerName: "api.internal"}); err != nil {
+			t.Errorf("Unexpected error: %v\n", err)
+		}
+
+		if !called {
+			t.Error("Expected the per-client configuration to run")
+		}
+	}
+}
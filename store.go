@@ -0,0 +1,198 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// CertStore keeps certificates by name, e.g. by the host name they are
+// served for
+type CertStore interface {
+	// Store saves the certificate under the name, replacing any previous one
+	Store(name string, cert tls.Certificate) error
+
+	// Load returns the certificate saved under the name, if any
+	Load(name string) (tls.Certificate, bool)
+
+	// Delete removes the certificate saved under the name. Deleting a name
+	// that is not in the store is not an error.
+	Delete(name string) error
+}
+
+// MemoryCertStore is a CertStore that keeps the certificates in memory.
+// It is safe for concurrent use.
+type MemoryCertStore struct {
+	mu    sync.RWMutex
+	certs map[string]tls.Certificate
+}
+
+// NewMemoryCertStore creates an empty in-memory certificate store
+func NewMemoryCertStore() *MemoryCertStore {
+	return &MemoryCertStore{certs: make(map[string]tls.Certificate)}
+}
+
+// Store saves the certificate under the name
+func (s *MemoryCertStore) Store(name string, cert tls.Certificate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.certs[name] = cert
+
+	return nil
+}
+
+// Load returns the certificate saved under the name, if any
+func (s *MemoryCertStore) Load(name string) (tls.Certificate, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cert, ok := s.certs[name]
+
+	return cert, ok
+}
+
+// Delete removes the certificate saved under the name
+func (s *MemoryCertStore) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.certs, name)
+
+	return nil
+}
+
+// FileCertStore is a CertStore that persists each certificate as a pair of
+// PEM files, <name>.crt and <name>.key, in a directory. The key files are only
+// readable by their owner.
+type FileCertStore struct {
+	dir string
+}
+
+// NewFileCertStore creates a certificate store in the directory, creating the
+// directory if it does not exist
+func NewFileCertStore(dir string) (*FileCertStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("privatetls: creating certificate store: %w", err)
+	}
+
+	return &FileCertStore{dir: dir}, nil
+}
+
+var errInvalidName = errors.New("privatetls: certificate name must be a non-empty file name")
+
+// Store writes the certificate chain and private key under the name
+func (s *FileCertStore) Store(name string, cert tls.Certificate) error {
+	certPath, keyPath, err := s.paths(name)
+	if err != nil {
+		return err
+	}
+
+	certPEM, keyPEM, err := encodeTLSCertificate(cert)
+	if err != nil {
+		return err
+	}
+
+	return writeCertAndKeyFiles(certPath, keyPath, certPEM, keyPEM)
+}
+
+// Load reads the certificate saved under the name, if any
+func (s *FileCertStore) Load(name string) (tls.Certificate, bool) {
+	certPath, keyPath, err := s.paths(name)
+	if err != nil {
+		return tls.Certificate{}, false
+	}
+
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return tls.Certificate{}, false
+	}
+
+	return cert, true
+}
+
+// Delete removes the files of the certificate saved under the name
+func (s *FileCertStore) Delete(name string) error {
+	certPath, keyPath, err := s.paths(name)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range []string{keyPath, certPath} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("privatetls: deleting certificate %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// The certificate and key file paths for a name, which must not escape the directory
+func (s *FileCertStore) paths(name string) (certPath, keyPath string, err error) {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", "", errInvalidName
+	}
+
+	base := filepath.Join(s.dir, name)
+
+	return base + ".crt", base + ".key", nil
+}
+
+// PEM encode the certificate chain and the private key of a TLS certificate
+func encodeTLSCertificate(cert tls.Certificate) (certPEM, keyPEM []byte, err error) {
+	if len(cert.Certificate) == 0 {
+		return nil, nil, errEmptyChain
+	}
+
+	for _, der := range cert.Certificate {
+		certPEM = append(certPEM, DERToPEM(der, "CERTIFICATE")...)
+	}
+
+	keyPEM, err = encodePrivateKey(cert.PrivateKey)
+
+	return
+}
+
+// TLSConfigFromStore creates a TLS configuration that selects the certificate
+// by the SNI name sent by the client, falling back to the certificate saved
+// under defaultName. SNI names are matched case-insensitively, ignoring a
+// trailing dot.
+func TLSConfigFromStore(store CertStore, defaultName string) *tls.Config {
+	return &tls.Config{
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			name := normalizeServerName(hello.ServerName)
+
+			if cert, ok := store.Load(name); ok && name != "" {
+				return &cert, nil
+			}
+
+			if cert, ok := store.Load(defaultName); ok {
+				return &cert, nil
+			}
+
+			return nil, fmt.Errorf("privatetls: no certificate for server name %q", hello.ServerName)
+		},
+	}
+}
+
+// Server names are case-insensitive, and may be sent fully qualified with a trailing dot
+func normalizeServerName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(name), ".")
+}
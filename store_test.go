@@ -0,0 +1,125 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"bytes"
+	"crypto/tls"
+	"testing"
+)
+
+func TestCertStores(t *testing.T) {
+	fileStore, err := NewFileCertStore(t.TempDir())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	cert, err := NewCert(WithEd25519())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	for _, store := range []CertStore{NewMemoryCertStore(), fileStore} {
+		if _, ok := store.Load("localhost"); ok {
+			t.Errorf("%T: Expected no certificate in an empty store\n", store)
+		}
+
+		if err := store.Store("localhost", cert); err != nil {
+			t.Fatalf("%T: Unexpected error: %v\n", store, err)
+		}
+
+		loaded, ok := store.Load("localhost")
+
+		if !ok || !bytes.Equal(loaded.Certificate[0], cert.Certificate[0]) {
+			t.Errorf("%T: Expected the stored certificate to be loaded\n", store)
+		}
+
+		if err := store.Delete("localhost"); err != nil {
+			t.Errorf("%T: Unexpected error: %v\n", store, err)
+		}
+
+		if _, ok := store.Load("localhost"); ok {
+			t.Errorf("%T: Expected the certificate to be deleted\n", store)
+		}
+
+		if err := store.Delete("localhost"); err != nil {
+			t.Errorf("%T: Unexpected error deleting a missing certificate: %v\n", store, err)
+		}
+	}
+}
+
+func TestFileCertStoreInvalidName(t *testing.T) {
+	store, err := NewFileCertStore(t.TempDir())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	for _, name := range []string{"", "..", "../escape", "a/b"} {
+		if err := store.Store(name, tls.Certificate{}); err == nil {
+			t.Errorf("Expected an error for name %q\n", name)
+		}
+	}
+}
+
+func TestTLSConfigFromStore(t *testing.T) {
+	store := NewMemoryCertStore()
+
+	defaultCert, err := NewCert(WithEd25519())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	apiCert, err := NewCert(WithEd25519(), WithDNSNames("api.internal"))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	store.Store("default", defaultCert)
+	store.Store("api.internal", apiCert)
+
+	cfg := TLSConfigFromStore(store, "default")
+
+	tests := []struct {
+		serverName string
+		want       tls.Certificate
+	}{
+		{"api.internal", apiCert},
+		{"API.Internal.", apiCert},
+		{"other.internal", defaultCert},
+		{"", defaultCert},
+	}
+
+	for _, tt := range tests {
+		got, err := cfg.GetCertificate(&tls.ClientHelloInfo{ServerName: tt.serverName})
+
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+
+		if !bytes.Equal(got.Certificate[0], tt.want.Certificate[0]) {
+			t.Errorf("Unexpected certificate selected for %q\n", tt.serverName)
+		}
+	}
+
+	store.Delete("default")
+
+	if _, err := cfg.GetCertificate(&tls.ClientHelloInfo{ServerName: "other.internal"}); err == nil {
+		t.Error("Expected an error without a default certificate")
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package testing provides test helpers that serve HTTPS with certificates
+// generated by privatetls, so that tests exercise the same code path as the
+// servers under test. Import it under another name, since it shares its name
+// with the standard testing package.
+package testing
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	stdtesting "testing"
+
+	"github.com/netbucket/privatetls"
+)
+
+// NewTLSTestServer starts an httptest.Server presenting a self-signed
+// certificate freshly generated with privatetls.NewCert, rather than the fixed
+// certificate of httptest.NewTLSServer. The client of the server trusts the
+// certificate through its RootCAs, so requests succeed without
+// InsecureSkipVerify. The server is closed when the test finishes.
+func NewTLSTestServer(tb stdtesting.TB, handler http.Handler) *httptest.Server {
+	tb.Helper()
+
+	cert, err := privatetls.NewCert(privatetls.WithEd25519())
+	if err != nil {
+		tb.Fatalf("privatetls: generating the test server certificate: %v", err)
+	}
+
+	s := httptest.NewUnstartedServer(handler)
+	// StartTLS keeps the certificate and makes the client trust it
+	s.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	s.StartTLS()
+	tb.Cleanup(s.Close)
+
+	return s
+}
+
+// BenchmarkHandshake runs b.N full TLS handshakes over net.Pipe, like
+// privatetls.MeasureHandshakeLatency, for use in the benchmarks of a package.
+// If clientCert has certificates, the server requires the client to present
+// it. Session resumption is disabled, so every handshake is a full one.
+func BenchmarkHandshake(b *stdtesting.B, clientCert, serverCert tls.Certificate) {
+	b.Helper()
+
+	serverConfig := &tls.Config{
+		Certificates:           []tls.Certificate{serverCert},
+		SessionTicketsDisabled: true,
+	}
+	clientConfig := &tls.Config{InsecureSkipVerify: true}
+
+	if len(clientCert.Certificate) > 0 {
+		serverConfig.ClientAuth = tls.RequireAnyClientCert
+		clientConfig.Certificates = []tls.Certificate{clientCert}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := handshake(serverConfig, clientConfig); err != nil {
+			b.Fatalf("privatetls: handshake: %v", err)
+		}
+	}
+}
+
+// Perform one handshake over an in-memory connection
+func handshake(serverConfig, clientConfig *tls.Config) error {
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+
+	errCh := make(chan error, 1)
+	go func() {
+		defer serverConn.Close()
+		errCh <- tls.Server(serverConn, serverConfig).Handshake()
+	}()
+
+	clientErr := tls.Client(clientConn, clientConfig).Handshake()
+	// Unblock the server if the client gave up
+	clientConn.Close()
+
+	return errors.Join(clientErr, <-errCh)
+}
@@ -0,0 +1,75 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testing
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	stdtesting "testing"
+
+	"github.com/netbucket/privatetls"
+)
+
+func TestNewTLSTestServer(t *stdtesting.T) {
+	s := NewTLSTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "Hello from the test server!")
+	}))
+
+	cert := s.TLS.Certificates[0]
+	if !bytes.Equal(s.Certificate().Raw, cert.Certificate[0]) || cert.Leaf.Subject.Organization[0] != "PrivateTLS" {
+		t.Error("Expected the server to present a certificate generated by privatetls")
+	}
+
+	resp, err := s.Client().Get(s.URL)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	defer resp.Body.Close()
+
+	if body, _ := ioutil.ReadAll(resp.Body); string(body) != "Hello from the test server!" {
+		t.Errorf("Unexpected response: %q\n", body)
+	}
+}
+
+// Compare the handshake cost of the key algorithms, with mutual authentication
+
+func BenchmarkHandshakeRSA2048(b *stdtesting.B) {
+	benchmarkHandshake(b, privatetls.WithRSAKeyBits(2048))
+}
+
+func BenchmarkHandshakeECDSAP256(b *stdtesting.B) {
+	benchmarkHandshake(b, privatetls.WithECDSACurve(elliptic.P256()))
+}
+
+func BenchmarkHandshakeEd25519(b *stdtesting.B) {
+	benchmarkHandshake(b, privatetls.WithEd25519())
+}
+
+func benchmarkHandshake(b *stdtesting.B, opts ...privatetls.Option) {
+	serverCert, err := privatetls.NewCert(opts...)
+	if err != nil {
+		b.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	clientCert, err := privatetls.NewCert(opts...)
+	if err != nil {
+		b.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	BenchmarkHandshake(b, clientCert, serverCert)
+}
@@ -0,0 +1,205 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/tls"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	ticketKeyNameLength = 8
+	ticketKeyLength     = 32 // AES-256
+)
+
+// RotatingTicketKeys encrypts TLS session tickets with a key that is replaced
+// every interval. The previous keys are kept to decrypt the tickets issued
+// with them, until keyCount newer keys replaced them. Limiting how long a key
+// lives limits the sessions exposed if it leaks, preserving forward secrecy.
+type RotatingTicketKeys struct {
+	keyCount int
+	logger   *slog.Logger
+
+	mu   sync.RWMutex
+	keys []ticketKey // Newest first
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// A session ticket key, and the name identifying it in the tickets
+type ticketKey struct {
+	name [ticketKeyNameLength]byte
+	aead cipher.AEAD
+}
+
+// NewRotatingTicketKeys generates a ticket key and starts replacing it every
+// interval, keeping at most keyCount keys. Use Configure to encrypt the
+// session tickets of a TLS configuration with them, and Close to stop the
+// rotation.
+func NewRotatingTicketKeys(interval time.Duration, keyCount int, opts ...BackgroundOption) (*RotatingTicketKeys, error) {
+	if interval <= 0 {
+		return nil, errors.New("privatetls: rotation interval must be positive")
+	}
+
+	if keyCount < 1 {
+		return nil, errors.New("privatetls: at least one ticket key must be kept")
+	}
+
+	k := &RotatingTicketKeys{
+		keyCount: keyCount,
+		logger:   newBackgroundConfig(opts).logger,
+		done:     make(chan struct{}),
+	}
+
+	if err := k.rotate(); err != nil {
+		return nil, err
+	}
+
+	go k.run(interval)
+
+	return k, nil
+}
+
+// Configure encrypts the session tickets of the configuration with the
+// rotating keys, by setting WrapSession and UnwrapSession. Unlike
+// tls.Config.SetSessionTicketKeys, the keys are also used by clones of the
+// configuration, such as the one http.Server serves with.
+func (k *RotatingTicketKeys) Configure(cfg *tls.Config) {
+	cfg.SessionTicketsDisabled = false
+	cfg.WrapSession = k.wrap
+	cfg.UnwrapSession = k.unwrap
+}
+
+// Close stops the rotation. The current keys continue to be used.
+func (k *RotatingTicketKeys) Close() error {
+	k.closeOnce.Do(func() {
+		close(k.done)
+	})
+
+	return nil
+}
+
+// Rotate the keys on every tick until closed
+func (k *RotatingTicketKeys) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-k.done:
+			return
+		case <-ticker.C:
+			if err := k.rotate(); err != nil {
+				k.logger.Error("privatetls: session ticket key rotation failed", "error", err)
+				continue
+			}
+			k.logger.Debug("privatetls: rotated session ticket key")
+		}
+	}
+}
+
+// Generate a new key for encrypting tickets, dropping the oldest ones
+func (k *RotatingTicketKeys) rotate() error {
+	var key ticketKey
+	secret := make([]byte, ticketKeyLength)
+
+	if _, err := io.ReadFull(rand.Reader, key.name[:]); err != nil {
+		return err
+	}
+
+	if _, err := io.ReadFull(rand.Reader, secret); err != nil {
+		return err
+	}
+
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return err
+	}
+
+	if key.aead, err = cipher.NewGCM(block); err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.keys = append([]ticketKey{key}, k.keys...)
+	if len(k.keys) > k.keyCount {
+		k.keys = k.keys[:k.keyCount]
+	}
+
+	return nil
+}
+
+// Encrypt the session state with the newest key: name, nonce, then ciphertext
+func (k *RotatingTicketKeys) wrap(_ tls.ConnectionState, ss *tls.SessionState) ([]byte, error) {
+	state, err := ss.Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	k.mu.RLock()
+	key := k.keys[0]
+	k.mu.RUnlock()
+
+	ticket := make([]byte, ticketKeyNameLength+key.aead.NonceSize(), ticketKeyNameLength+key.aead.NonceSize()+len(state)+key.aead.Overhead())
+	copy(ticket, key.name[:])
+
+	nonce := ticket[ticketKeyNameLength:]
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return key.aead.Seal(ticket, nonce, state, key.name[:]), nil
+}
+
+// Decrypt a ticket issued with one of the kept keys. Tickets of discarded keys
+// are ignored, which makes the client perform a full handshake.
+func (k *RotatingTicketKeys) unwrap(ticket []byte, _ tls.ConnectionState) (*tls.SessionState, error) {
+	if len(ticket) < ticketKeyNameLength {
+		return nil, nil
+	}
+
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	for _, key := range k.keys {
+		if !bytes.Equal(ticket[:ticketKeyNameLength], key.name[:]) {
+			continue
+		}
+
+		rest := ticket[ticketKeyNameLength:]
+		if len(rest) < key.aead.NonceSize() {
+			return nil, nil
+		}
+
+		state, err := key.aead.Open(nil, rest[:key.aead.NonceSize()], rest[key.aead.NonceSize():], key.name[:])
+		if err != nil {
+			return nil, nil
+		}
+
+		return tls.ParseSessionState(state)
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,131 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"testing"
+	"time"
+)
+
+// Perform a handshake over an in-memory connection, reporting whether the
+// session was resumed
+func resumedHandshake(t *testing.T, serverConfig, clientConfig *tls.Config) bool {
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+
+	go func() {
+		defer serverConn.Close()
+
+		server := tls.Server(serverConn, serverConfig)
+		if err := server.Handshake(); err != nil {
+			return
+		}
+		// Send data behind the session ticket, so the client reads the ticket
+		server.Write([]byte{0})
+	}()
+
+	client := tls.Client(clientConn, clientConfig)
+	if _, err := client.Read(make([]byte, 1)); err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	return client.ConnectionState().DidResume
+}
+
+func TestRotatingTicketKeys(t *testing.T) {
+	cert, err := NewCert(WithEd25519())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	keys, err := NewRotatingTicketKeys(time.Hour, 2)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	defer keys.Close()
+
+	serverConfig := &tls.Config{Certificates: []tls.Certificate{cert}}
+	keys.Configure(serverConfig)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert.Leaf)
+	clientConfig := &tls.Config{
+		RootCAs:            roots,
+		ServerName:         "localhost",
+		ClientSessionCache: tls.NewLRUClientSessionCache(1),
+	}
+
+	if resumedHandshake(t, serverConfig, clientConfig) {
+		t.Error("Expected the first handshake not to resume a session")
+	}
+
+	// The keys are shared by clones, e.g. the one made by http.Server
+	if !resumedHandshake(t, serverConfig.Clone(), clientConfig) {
+		t.Error("Expected the session to be resumed")
+	}
+
+	// A ticket remains valid until its key is discarded
+	keys.rotate()
+	if !resumedHandshake(t, serverConfig, clientConfig) {
+		t.Error("Expected the session to be resumed after a rotation")
+	}
+
+	keys.rotate()
+	keys.rotate()
+	if resumedHandshake(t, serverConfig, clientConfig) {
+		t.Error("Expected the session not to be resumed with a discarded key")
+	}
+}
+
+func TestRotatingTicketKeysRotation(t *testing.T) {
+	keys, err := NewRotatingTicketKeys(10*time.Millisecond, 3)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	defer keys.Close()
+
+	keys.mu.RLock()
+	initial := keys.keys[0].name
+	keys.mu.RUnlock()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		keys.mu.RLock()
+		current, count := keys.keys[0].name, len(keys.keys)
+		keys.mu.RUnlock()
+
+		if current != initial && count == 3 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Error("Ticket keys were not rotated")
+}
+
+func TestRotatingTicketKeysInvalid(t *testing.T) {
+	if _, err := NewRotatingTicketKeys(0, 2); err == nil {
+		t.Error("Expected an error for a zero interval")
+	}
+
+	if _, err := NewRotatingTicketKeys(time.Hour, 0); err == nil {
+		t.Error("Expected an error for no keys")
+	}
+}
@@ -14,107 +14,466 @@
 package privatetls
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha1"
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
-	"encoding/pem"
-	"math/big"
-	"net"
-	"net/http"
+	"encoding/asn1"
+	"errors"
+	"io"
 	"time"
 )
 
 const (
 	rsaKeyLength     = 2048
+	minRSAKeyLength  = 512
 	serialNumberBits = 128
+	defaultValidity  = time.Hour * 24 * 365 // Make it valid for a year
+	notBeforeGrace   = time.Minute          // Tolerate clients with clocks slightly behind
 )
 
-// StartHTTPSListener starts an HTTPS server at the address specified
-// by the service parameter using self-signed TLS certificate. If blank,
-// the default value of ":https" is used. The listener will use a self-signed
-// RSA based TLS certificate with a random 2048 bit key.
-// The certificate is valid for 1 year.
-func StartHTTPSListener(service string) error {
-	s := http.Server{}
+// NewCert Generates a self-signed TLS certificate. By default the certificate
+// will use the x509.SHA256WithRSA algorithm with a random 2048 bit key,
+// will be valid for 1 year, and will cover localhost, 127.0.0.1 and ::1.
+// The Leaf field of the returned certificate is populated.
+// The defaults can be changed with the supplied options, e.g.
+// NewCert(WithDNSNames("myservice.internal"), WithValidity(time.Hour)).
+func NewCert(opts ...Option) (tls.Certificate, error) {
+	return newCert(newCertConfig(opts))
+}
+
+// NewCertPEM Generates a self-signed certificate like NewCert, returning the
+// PEM-encoded certificate and private key instead of a tls.Certificate.
+// The key is encrypted if the WithEncryptedKey option is set.
+func NewCertPEM(opts ...Option) (certPEM, keyPEM []byte, err error) {
+	cfg := newCertConfig(opts)
 
-	s.Addr = service
+	certPEM, keyPEM, err = newCertPEM(cfg)
+	if err == nil && cfg.keyPassword != nil {
+		keyPEM, err = encryptKeyPEM(keyPEM, cfg.keyPassword, x509.PEMCipherAES256)
+	}
 
-	selfSignedCert, err := NewCert()
+	return
+}
+
+// NewCertECDSA Generates a self-signed TLS certificate using a random ECDSA
+// key on the supplied curve. The certificate is signed with SHA-256 for P-256,
+// SHA-384 for P-384 and SHA-512 for P-521, and will be valid for 1 year.
+func NewCertECDSA(curve elliptic.Curve) (tls.Certificate, error) {
+	return NewCert(WithECDSACurve(curve))
+}
+
+// NewCertEd25519 Generates a self-signed TLS certificate using a random Ed25519
+// key. Ed25519 keys are generated dramatically faster than RSA keys, which
+// makes this a good fit for test suites. The certificate will be valid for 1 year.
+func NewCertEd25519() (tls.Certificate, error) {
+	return NewCert(WithEd25519())
+}
 
+// NewCertWithOptions Generates a self-signed TLS certificate as described by
+// opts. Zero valued fields of opts fall back to the defaults used by NewCert.
+func NewCertWithOptions(opts CertOptions) (tls.Certificate, error) {
+	cfg, err := opts.config()
 	if err != nil {
-		return err
+		return tls.Certificate{}, err
 	}
 
-	s.TLSConfig = &tls.Config{
-		Certificates: []tls.Certificate{selfSignedCert},
+	return newCert(cfg)
+}
+
+// NewCertFromSigner Generates a self-signed TLS certificate, as configured by
+// the options, for an existing key rather than a new one, e.g. a key held in
+// an HSM that only exposes its public key and signing operations. The key
+// type options do not apply. The PrivateKey of the returned certificate is
+// the signer, so no key material needs to leave it.
+func NewCertFromSigner(signer crypto.Signer, opts ...Option) (tls.Certificate, error) {
+	if signer == nil {
+		return tls.Certificate{}, errors.New("privatetls: signer must not be nil")
 	}
 
-	return s.ListenAndServeTLS("", "")
+	cfg := newCertConfig(opts)
+	if err := cfg.validate(); err != nil {
+		return tls.Certificate{}, err
+	}
+
+	certPEM, err := issueCertPEMForKey(cfg, selfSignedAttributes(cfg), signer.Public(), nil, signer)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	leaf, err := PEMToCertificate(certPEM)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{Certificate: [][]byte{leaf.Raw}, PrivateKey: signer, Leaf: leaf}, nil
 }
 
-// NewCert Generates a self-signed TLS certificate. The certificate will
-// use the x509.SHA256WithRSA algorithm with a random 2048 bit key,
-// and will be valid for 1 year.
-func NewCert() (tls.Certificate, error) {
-	rootKey, err := rsa.GenerateKey(rand.Reader, rsaKeyLength)
+// Generate a key and a self-signed certificate described by the configuration
+func newCert(cfg *certConfig) (tls.Certificate, error) {
+	rootCertPEM, rootKeyPEM, err := newCertPEM(cfg)
 	if err != nil {
 		return tls.Certificate{}, err
 	}
 
-	t, err := createX509Template()
+	// Create a TLS cert using the private key and certificate
+	return x509KeyPair(rootCertPEM, rootKeyPEM)
+}
+
+// Parse a PEM-encoded certificate and key like tls.X509KeyPair, making sure
+// the parsed leaf is populated so callers can use cert.Leaf directly
+func x509KeyPair(certPEM, keyPEM []byte) (tls.Certificate, error) {
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
 	if err != nil {
 		return tls.Certificate{}, err
 	}
 
+	if cert.Leaf == nil {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return tls.Certificate{}, err
+		}
+	}
+
+	return cert, nil
+}
+
+// Generate a key and a self-signed certificate, both PEM-encoded
+func newCertPEM(cfg *certConfig) (certPEM, keyPEM []byte, err error) {
+	return issueCertPEM(cfg, selfSignedAttributes(cfg), nil, nil)
+}
+
+// Generate a key and a certificate for it, signed by the parent certificate
+// and key, or self-signed if parent is nil. The attributes function sets the
+// profile specific fields of the template, such as the key usage.
+func issueCertPEM(cfg *certConfig, attributes func(*x509.Certificate, crypto.PublicKey),
+	parent *x509.Certificate, parentKey crypto.Signer) (certPEM, keyPEM []byte, err error) {
+	if err = cfg.validate(); err != nil {
+		return
+	}
+
+	key, err := generateKey(cfg)
+	if err != nil {
+		return
+	}
+
+	if parent == nil {
+		parentKey = key
+	}
+
+	certPEM, err = issueCertPEMForKey(cfg, attributes, key.Public(), parent, parentKey)
+	if err != nil {
+		return
+	}
+
+	// Print the cert
+	//fmt.Printf("%s\n", certPEM)
+
+	if cfg.pkcs8Key {
+		keyPEM, err = encodePKCS8PrivateKey(key)
+	} else {
+		keyPEM, err = encodePrivateKey(key)
+	}
+
+	return
+}
+
+// Issue a certificate for an existing public key, see issueCertPEM. If parent
+// is nil the certificate is self-signed, and parentKey is the key of pub. The
+// configuration must already be validated.
+func issueCertPEMForKey(cfg *certConfig, attributes func(*x509.Certificate, crypto.PublicKey),
+	pub crypto.PublicKey, parent *x509.Certificate, parentKey crypto.Signer) ([]byte, error) {
+	t, err := createX509Template(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	attributes(t, pub)
+	setKeyUsage(t, cfg)
+
+	// Name constraints only mean something for certificates that sign others
+	if t.IsCA {
+		setNameConstraints(t, cfg)
+	}
+
+	issuer := parent
+	if parent == nil {
+		parent = t
+	}
+	t.SignatureAlgorithm = signatureAlgorithm(parentKey.Public())
+
+	if cfg.autoKeyIDs {
+		if err := setKeyIDs(t, pub, parent); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(cfg.ctLogs) > 0 {
+		if err := embedSCTs(cfg, t, pub, issuer, parentKey); err != nil {
+			return nil, err
+		}
+	}
+
+	certPEM, err := createCertFromTemplate(cfg.randReader(), t, parent, pub, parentKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := auditCertPEM(cfg.auditLog, certPEM, issuer); err != nil {
+		return nil, err
+	}
+
+	return certPEM, nil
+}
+
+// Generate a random private key of the configured type
+func generateKey(cfg *certConfig) (crypto.Signer, error) {
+	switch cfg.keyType {
+	case KeyTypeECDSA:
+		return ecdsa.GenerateKey(cfg.curve, cfg.randReader())
+	case KeyTypeEd25519:
+		// Derive the key from a seed, which keeps it reproducible for a given reader
+		seed := make([]byte, ed25519.SeedSize)
+		if _, err := io.ReadFull(cfg.randReader(), seed); err != nil {
+			return nil, err
+		}
+		return ed25519.NewKeyFromSeed(seed), nil
+	default:
+		return rsa.GenerateKey(cfg.randReader(), cfg.rsaBits)
+	}
+}
+
+// PEM encode the private key, using the dedicated block type where one exists
+func encodePrivateKey(key crypto.PrivateKey) ([]byte, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return DERToPEM(x509.MarshalPKCS1PrivateKey(k), "RSA PRIVATE KEY"), nil
+	case *ecdsa.PrivateKey:
+		der, err := x509.MarshalECPrivateKey(k)
+		if err != nil {
+			return nil, err
+		}
+		return DERToPEM(der, "EC PRIVATE KEY"), nil
+	default:
+		// Ed25519 has no dedicated PEM block type, so use PKCS8
+		return encodePKCS8PrivateKey(k)
+	}
+}
+
+// PEM encode the private key as an algorithm-agnostic PKCS8 block
+func encodePKCS8PrivateKey(key crypto.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return DERToPEM(der, "PRIVATE KEY"), nil
+}
+
+// Pick the signature algorithm matching the type and strength of the signing key
+func signatureAlgorithm(pub crypto.PublicKey) x509.SignatureAlgorithm {
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		switch bits := k.Curve.Params().BitSize; {
+		case bits > 384:
+			return x509.ECDSAWithSHA512
+		case bits > 256:
+			return x509.ECDSAWithSHA384
+		default:
+			return x509.ECDSAWithSHA256
+		}
+	case ed25519.PublicKey:
+		return x509.PureEd25519
+	default:
+		return x509.SHA256WithRSA
+	}
+}
+
+// The attributes of a self-signed certificate, a CA unless a profile asks for a leaf
+func selfSignedAttributes(cfg *certConfig) func(*x509.Certificate, crypto.PublicKey) {
+	if cfg.selfSignedLeaf {
+		return setLeafAttributes
+	}
+
+	return setSelfSignedAttributes
+}
+
+// Mark the template as a self-signed CA certificate that is usable by a local server
+func setSelfSignedAttributes(t *x509.Certificate, _ crypto.PublicKey) {
 	t.IsCA = true
 	t.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
 	t.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
-	t.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+}
 
-	rootCertPEM, err := createCertFromTemplate(t, rootKey)
+// Replace the key usages of the kind of certificate with the configured ones
+func setKeyUsage(t *x509.Certificate, cfg *certConfig) {
+	if cfg.keyUsage != 0 {
+		t.KeyUsage = cfg.keyUsage
+	}
 
+	if cfg.extKeyUsage != nil {
+		t.ExtKeyUsage = cfg.extKeyUsage
+	}
+}
+
+// Identify the key of the template, and that of its issuer, which is the
+// template itself for self-signed certificates
+func setKeyIDs(t *x509.Certificate, pub crypto.PublicKey, parent *x509.Certificate) error {
+	id, err := subjectKeyID(pub)
 	if err != nil {
-		return tls.Certificate{}, err
+		return err
 	}
 
-	// Print the self-signed cert
-	//fmt.Printf("%s\n", rootCertPEM)
+	t.SubjectKeyId = id
+	if parent == t {
+		t.AuthorityKeyId = id
+	} else {
+		t.AuthorityKeyId = parent.SubjectKeyId
+	}
 
-	// PEM encode the private key
-	rootKeyPEM := pem.EncodeToMemory(&pem.Block{
-		Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rootKey),
-	})
+	return nil
+}
 
-	// Create a TLS cert using the private key and certificate
-	return tls.X509KeyPair(rootCertPEM, rootKeyPEM)
+// Compute the key identifier of RFC 5280 method 1: the SHA-1 hash of the
+// subjectPublicKey bit string, without its tag, length and unused bits
+func subjectKeyID(pub crypto.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(der, &spki); err != nil {
+		return nil, err
+	}
+
+	sum := sha1.Sum(spki.PublicKey.Bytes)
+	return sum[:], nil
+}
+
+// Restrict the names the CA certificate can sign
+func setNameConstraints(t *x509.Certificate, cfg *certConfig) {
+	t.PermittedDNSDomains = cfg.permittedDNSDomains
+	t.ExcludedDNSDomains = cfg.excludedDNSDomains
+	t.PermittedIPRanges = cfg.permittedIPRanges
+	t.ExcludedIPRanges = cfg.excludedIPRanges
+}
+
+// NewX509Template returns a certificate template configured by the options,
+// with the serial number, validity period, subject, SANs and extensions
+// NewCert would use, for building custom hierarchies with
+// CreateCertFromTemplate. The CA flag, the name constraints and, unless set
+// with WithKeyUsage or WithExtKeyUsage, the key usages are left for the
+// caller to set. The signature algorithm is left unset too, so that
+// crypto/x509 picks one matching the signing key. Key type options do not
+// apply.
+func NewX509Template(opts ...Option) (*x509.Certificate, error) {
+	cfg := newCertConfig(opts)
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	t, err := createX509Template(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	t.SignatureAlgorithm = x509.UnknownSignatureAlgorithm
+	setKeyUsage(t, cfg)
+
+	return t, nil
+}
+
+// CreateCertFromTemplate creates a certificate for the public key from the
+// template, signed by the parent certificate and signer, and returns it
+// PEM-encoded. Pass the template as the parent, and the signer of pub, to
+// create a self-signed certificate.
+func CreateCertFromTemplate(template, parent *x509.Certificate, pub crypto.PublicKey, signer crypto.Signer) ([]byte, error) {
+	if template == nil || parent == nil || pub == nil || signer == nil {
+		return nil, errors.New("privatetls: template, parent, public key and signer must not be nil")
+	}
+
+	return createCertFromTemplate(rand.Reader, template, parent, pub, signer)
 }
 
 // Create a certificate template
-func createX509Template() (*x509.Certificate, error) {
-	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), serialNumberBits)
-	serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
+func createX509Template(cfg *certConfig) (*x509.Certificate, error) {
+	serials := cfg.serials
+	if serials == nil {
+		serials = randomSerial{cfg.randReader()}
+	}
 
+	serialNumber, err := serials.Next()
 	if err != nil {
 		return nil, err
 	}
 
+	if serialNumber == nil || serialNumber.Sign() <= 0 {
+		return nil, errors.New("privatetls: serial number must be positive")
+	}
+
+	// The validity period starts at generation, back-dated by the grace period,
+	// and always spans exactly validFor
+	notBefore := cfg.notBefore
+	if notBefore.IsZero() {
+		notBefore = time.Now().Add(-cfg.notBeforeOffset)
+	}
+	notAfter := notBefore.Add(cfg.validFor)
+
 	t := x509.Certificate{
 		SerialNumber:          serialNumber,
-		Subject:               pkix.Name{Organization: []string{"PrivateTLS"}},
+		Subject:               cfg.subject(),
 		SignatureAlgorithm:    x509.SHA256WithRSA,
-		NotBefore:             time.Now(),
-		NotAfter:              time.Now().Add(time.Hour * 24 * 365), // Make it valid for a year
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
 		BasicConstraintsValid: true,
+		IPAddresses:           cfg.ipAddresses,
+		DNSNames:              cfg.dnsNames,
+		URIs:                  cfg.uris,
+		EmailAddresses:        cfg.emailAddresses,
+		CRLDistributionPoints: cfg.crlDistributionPoints,
+		OCSPServer:            cfg.ocspServers,
+		ExtraExtensions:       append([]pkix.Extension(nil), cfg.extraExtensions...),
+		PolicyIdentifiers:     cfg.policyIdentifiers,
+	}
+
+	// crypto/x509 encodes Policies rather than PolicyIdentifiers since Go 1.24
+	for _, id := range cfg.policyIdentifiers {
+		ints := make([]uint64, len(id))
+		for i, v := range id {
+			ints[i] = uint64(v)
+		}
+
+		oid, err := x509.OIDFromInts(ints)
+		if err != nil {
+			return nil, err
+		}
+		t.Policies = append(t.Policies, oid)
+	}
+
+	if len(cfg.scts) > 0 {
+		ext, err := sctListExtension(cfg.scts)
+		if err != nil {
+			return nil, err
+		}
+		t.ExtraExtensions = append(t.ExtraExtensions, ext)
 	}
 
 	return &t, nil
 }
 
-// Create a self-signed certificate, PEM-encoded in an in-memory byte array, using a supplied template
-func createCertFromTemplate(template *x509.Certificate, key *rsa.PrivateKey) (certPEM []byte, err error) {
-	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+// Create a certificate signed by the parent, PEM-encoded in an in-memory byte array, using a supplied template.
+// Pass the template as the parent to create a self-signed certificate.
+func createCertFromTemplate(random io.Reader, template, parent *x509.Certificate, pub, priv interface{}) (certPEM []byte, err error) {
+	certDER, err := x509.CreateCertificate(random, template, parent, pub, priv)
 	if err != nil {
 		return
 	}
@@ -124,8 +483,7 @@ func createCertFromTemplate(template *x509.Certificate, key *rsa.PrivateKey) (ce
 		return
 	}
 
-	b := pem.Block{Type: "CERTIFICATE", Bytes: certDER}
-	certPEM = pem.EncodeToMemory(&b)
+	certPEM = DERToPEM(certDER, "CERTIFICATE")
 
 	return
 }
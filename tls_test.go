@@ -14,9 +14,18 @@
 package privatetls
 
 import (
+	"bytes"
+	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/rsa"
+	"crypto/tls"
 	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"net"
 	"testing"
 	"time"
 )
@@ -68,3 +77,239 @@ func TestCertExpiration(t *testing.T) {
 		t.Errorf("Certificate expiration date is %v, expected before %v", x509Cert.NotAfter, expiresBefore)
 	}
 }
+
+func TestNewCertECDSA(t *testing.T) {
+	tests := []struct {
+		curve elliptic.Curve
+		alg   x509.SignatureAlgorithm
+	}{
+		{elliptic.P256(), x509.ECDSAWithSHA256},
+		{elliptic.P384(), x509.ECDSAWithSHA384},
+		{elliptic.P521(), x509.ECDSAWithSHA512},
+	}
+
+	for _, tt := range tests {
+		cert, err := NewCertECDSA(tt.curve)
+
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+
+		key, ok := cert.PrivateKey.(*ecdsa.PrivateKey)
+		if !ok {
+			t.Fatalf("Expected an ECDSA private key, got %T\n", cert.PrivateKey)
+		}
+
+		if key.Curve != tt.curve {
+			t.Errorf("Private key curve is %s, expected %s\n", key.Curve.Params().Name, tt.curve.Params().Name)
+		}
+
+		x509Cert, err := x509.ParseCertificate(cert.Certificate[0])
+
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+
+		if x509Cert.SignatureAlgorithm != tt.alg {
+			t.Errorf("Signature algorithm is %v, expected %v\n", x509Cert.SignatureAlgorithm, tt.alg)
+		}
+
+		if !x509Cert.IsCA || len(x509Cert.IPAddresses) != 2 || !x509Cert.IPAddresses[0].Equal(net.ParseIP("127.0.0.1")) {
+			t.Errorf("Unexpected certificate attributes: IsCA=%v, IPAddresses=%v\n", x509Cert.IsCA, x509Cert.IPAddresses)
+		}
+	}
+}
+
+func TestNewCertECDSANilCurve(t *testing.T) {
+	if _, err := NewCertECDSA(nil); err == nil {
+		t.Error("Expected an error for a nil curve")
+	}
+}
+
+func TestNewCertEd25519(t *testing.T) {
+	cert, err := NewCertEd25519()
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if _, ok := cert.PrivateKey.(ed25519.PrivateKey); !ok {
+		t.Fatalf("Expected an Ed25519 private key, got %T\n", cert.PrivateKey)
+	}
+
+	x509Cert, err := x509.ParseCertificate(cert.Certificate[0])
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if x509Cert.SignatureAlgorithm != x509.PureEd25519 {
+		t.Errorf("Signature algorithm is %v, expected %v\n", x509Cert.SignatureAlgorithm, x509.PureEd25519)
+	}
+}
+
+func TestDefaultSANs(t *testing.T) {
+	cert, err := NewCert(WithEd25519())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	x509Cert, err := x509.ParseCertificate(cert.Certificate[0])
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	for _, host := range []string{"localhost", "127.0.0.1", "::1"} {
+		if err := x509Cert.VerifyHostname(host); err != nil {
+			t.Errorf("Certificate is not valid for %s: %v\n", host, err)
+		}
+	}
+}
+
+func TestNewCertPEM(t *testing.T) {
+	certPEM, keyPEM, err := NewCertPEM(WithEd25519())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if b, _ := pem.Decode(certPEM); b == nil || b.Type != "CERTIFICATE" {
+		t.Errorf("Expected a CERTIFICATE PEM block, got %q\n", certPEM)
+	}
+
+	if b, _ := pem.Decode(keyPEM); b == nil || b.Type != "PRIVATE KEY" {
+		t.Errorf("Expected a PRIVATE KEY PEM block, got %q\n", keyPEM)
+	}
+
+	if _, err := tls.X509KeyPair(certPEM, keyPEM); err != nil {
+		t.Errorf("Unexpected error: %v\n", err)
+	}
+}
+
+func TestLeafPopulated(t *testing.T) {
+	cert, err := NewCert(WithEd25519())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if cert.Leaf == nil {
+		t.Fatal("Expected the leaf certificate to be populated")
+	}
+
+	if !bytes.Equal(cert.Leaf.Raw, cert.Certificate[0]) {
+		t.Error("Leaf does not match the first certificate in the chain")
+	}
+}
+
+func TestCreateCertFromTemplate(t *testing.T) {
+	_, rootKey, err := ed25519.GenerateKey(rand.Reader)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	root, err := NewX509Template(WithCommonName("Custom Root"), WithDNSNames(), WithIPAddresses())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	root.IsCA = true
+	root.KeyUsage = x509.KeyUsageCertSign
+
+	rootPEM, err := CreateCertFromTemplate(root, root, rootKey.Public(), rootKey)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	rootCert, err := PEMToCertificate(rootPEM)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	leaf, err := NewX509Template(WithDNSNames("svc.internal"), WithExtKeyUsage(x509.ExtKeyUsageServerAuth))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	leafPEM, err := CreateCertFromTemplate(leaf, rootCert, leafKey.Public(), rootKey)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	leafCert, err := PEMToCertificate(leafPEM)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(rootCert)
+
+	if _, err := leafCert.Verify(x509.VerifyOptions{Roots: roots, DNSName: "svc.internal"}); err != nil {
+		t.Errorf("Unexpected error: %v\n", err)
+	}
+
+	if _, err := CreateCertFromTemplate(leaf, nil, leafKey.Public(), rootKey); err == nil {
+		t.Error("Expected an error for a nil parent")
+	}
+}
+
+// A key that only exposes its public key and signing, like an HSM-backed one
+type opaqueSigner struct {
+	signer crypto.Signer
+}
+
+func (s opaqueSigner) Public() crypto.PublicKey { return s.signer.Public() }
+
+func (s opaqueSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return s.signer.Sign(rand, digest, opts)
+}
+
+func TestNewCertFromSigner(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	cert, err := NewCertFromSigner(opaqueSigner{key}, WithCommonName("hsm"), WithValidity(time.Hour))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if !key.PublicKey.Equal(cert.Leaf.PublicKey) || cert.Leaf.Subject.CommonName != "hsm" {
+		t.Error("Expected a certificate for the signer's public key")
+	}
+
+	if _, ok := cert.PrivateKey.(opaqueSigner); !ok {
+		t.Errorf("Expected the signer as the private key, got %T\n", cert.PrivateKey)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert.Leaf)
+
+	err = handshake(&tls.Config{Certificates: []tls.Certificate{cert}}, &tls.Config{RootCAs: roots, ServerName: "localhost"})
+
+	if err != nil {
+		t.Errorf("Unexpected handshake error: %v\n", err)
+	}
+
+	if _, err := NewCertFromSigner(nil); err == nil {
+		t.Error("Expected an error for a nil signer")
+	}
+}
@@ -0,0 +1,296 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+)
+
+// TLSOption changes the TLS configuration built by NewTLSConfig
+type TLSOption func(*tls.Config) error
+
+// NewTLSConfig builds a TLS configuration from the options, ready to be used
+// as http.Server.TLSConfig. Unless the options supply certificates, a
+// self-signed certificate is generated with NewCert. Settings not changed by
+// the options keep the crypto/tls defaults.
+func NewTLSConfig(opts ...TLSOption) (*tls.Config, error) {
+	cfg := &tls.Config{}
+
+	for _, opt := range opts {
+		if err := opt(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.MinVersion != 0 && cfg.MaxVersion != 0 && cfg.MinVersion > cfg.MaxVersion {
+		return nil, fmt.Errorf("privatetls: minimum TLS version %s is above the maximum %s",
+			tls.VersionName(cfg.MinVersion), tls.VersionName(cfg.MaxVersion))
+	}
+
+	// A per-client configuration may keep the original one, which then needs a certificate
+	if len(cfg.Certificates) == 0 && cfg.GetCertificate == nil {
+		cert, err := NewCert()
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// WithCertificates sets the certificates presented by the server
+func WithCertificates(certs ...tls.Certificate) TLSOption {
+	return func(c *tls.Config) error {
+		c.Certificates = certs
+		return nil
+	}
+}
+
+// WithMinVersion sets the minimum accepted TLS version, e.g. tls.VersionTLS12
+func WithMinVersion(v uint16) TLSOption {
+	return func(c *tls.Config) error {
+		if err := checkTLSVersion(v); err != nil {
+			return err
+		}
+		c.MinVersion = v
+		return nil
+	}
+}
+
+// WithMaxVersion sets the maximum accepted TLS version, e.g. tls.VersionTLS13
+func WithMaxVersion(v uint16) TLSOption {
+	return func(c *tls.Config) error {
+		if err := checkTLSVersion(v); err != nil {
+			return err
+		}
+		c.MaxVersion = v
+		return nil
+	}
+}
+
+// WithTLS13Only refuses connections from clients that do not support TLS 1.3
+func WithTLS13Only() TLSOption {
+	return WithMinVersion(tls.VersionTLS13)
+}
+
+// WithCipherSuites sets the TLS 1.0-1.2 cipher suites, see tls.CipherSuites.
+// TLS 1.3 cipher suites are not configurable in crypto/tls.
+func WithCipherSuites(suites []uint16) TLSOption {
+	return func(c *tls.Config) error {
+		for _, id := range suites {
+			if !isKnownCipherSuite(id) {
+				return fmt.Errorf("privatetls: unknown cipher suite 0x%04x", id)
+			}
+		}
+		c.CipherSuites = suites
+		return nil
+	}
+}
+
+// The TLS 1.2 cipher suites with an ephemeral ECDHE key exchange, in the
+// crypto/tls order of preference
+var forwardSecretCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+}
+
+// WithForwardSecrecyRequired only accepts connections whose session keys
+// cannot be recovered from a leaked certificate key. It requires TLS 1.2 or
+// later, and limits TLS 1.2 to the ECDHE cipher suites:
+//
+//	TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 (0xc02b)
+//	TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384 (0xc02c)
+//	TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256 (0xcca9)
+//	TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 (0xc02f)
+//	TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384 (0xc030)
+//	TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256 (0xcca8)
+//	TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA (0xc009)
+//	TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA (0xc00a)
+//	TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA (0xc013)
+//	TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA (0xc014)
+//
+// The suites with an RSA key exchange are excluded, i.e.
+// TLS_RSA_WITH_AES_128_CBC_SHA (0x002f), TLS_RSA_WITH_AES_256_CBC_SHA (0x0035),
+// TLS_RSA_WITH_AES_128_GCM_SHA256 (0x009c) and TLS_RSA_WITH_AES_256_GCM_SHA384
+// (0x009d), along with the insecure suites of tls.InsecureCipherSuites. All
+// TLS 1.3 cipher suites provide forward secrecy, so TLS 1.3 is unrestricted.
+func WithForwardSecrecyRequired() TLSOption {
+	return func(c *tls.Config) error {
+		if c.MinVersion < tls.VersionTLS12 {
+			c.MinVersion = tls.VersionTLS12
+		}
+		c.CipherSuites = append([]uint16(nil), forwardSecretCipherSuites...)
+		return nil
+	}
+}
+
+// WithCurves sets the key exchange curves in order of preference
+func WithCurves(curves []tls.CurveID) TLSOption {
+	return func(c *tls.Config) error {
+		c.CurvePreferences = curves
+		return nil
+	}
+}
+
+// WithSessionTicketsDisabled disables session resumption with session tickets
+func WithSessionTicketsDisabled() TLSOption {
+	return func(c *tls.Config) error {
+		c.SessionTicketsDisabled = true
+		return nil
+	}
+}
+
+// WithALPN sets the application protocols offered with ALPN, in order of
+// preference, e.g. "h2" and "http/1.1" for HTTP/2 with a fallback to HTTP/1.1,
+// or "grpc" and "mqtt". An http.Server only serves HTTP/2 when "h2" is offered.
+func WithALPN(protocols ...string) TLSOption {
+	return func(c *tls.Config) error {
+		for _, p := range protocols {
+			if len(p) == 0 || len(p) > 255 {
+				return fmt.Errorf("privatetls: invalid ALPN protocol %q", p)
+			}
+		}
+		c.NextProtos = protocols
+		return nil
+	}
+}
+
+// WithRenegotiationPolicy sets whether a client accepts TLS 1.2
+// renegotiation requests from the server, e.g. tls.RenegotiateOnceAsClient
+// for servers that request a client certificate after the handshake.
+// crypto/tls servers never renegotiate, so the policy has no effect on them.
+//
+// Renegotiation is only appropriate for legacy TLS 1.2 servers that require
+// it, such as some IIS deployments requesting client certificates per path;
+// prefer tls.RenegotiateOnceAsClient there. tls.RenegotiateFreelyAsClient lets
+// the server restart the handshake at any time, which weakens security, so
+// reserve it for servers that renegotiate repeatedly.
+func WithRenegotiationPolicy(policy tls.RenegotiationSupport) TLSOption {
+	return func(c *tls.Config) error {
+		if policy < tls.RenegotiateNever || policy > tls.RenegotiateFreelyAsClient {
+			return fmt.Errorf("privatetls: unknown renegotiation policy %d", policy)
+		}
+		c.Renegotiation = policy
+		return nil
+	}
+}
+
+// WithClientAuth sets the server policy for client certificates, e.g.
+// tls.RequireAndVerifyClientCert
+func WithClientAuth(mode tls.ClientAuthType) TLSOption {
+	return func(c *tls.Config) error {
+		if mode < tls.NoClientCert || mode > tls.RequireAndVerifyClientCert {
+			return fmt.Errorf("privatetls: unknown client authentication type %d", mode)
+		}
+		c.ClientAuth = mode
+		return nil
+	}
+}
+
+// WithVerifyPeerCertificate sets tls.Config.VerifyPeerCertificate, a custom
+// check of the certificates of the peer run after the normal verification,
+// e.g. to require custom extensions or key algorithms, or to pin
+// certificates. Returning an error aborts the handshake. When the normal
+// verification is skipped, i.e. InsecureSkipVerify is set on a client or the
+// client authentication type of a server does not verify certificates, the
+// callback still receives the raw certificates but verifiedChains is nil.
+func WithVerifyPeerCertificate(fn func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error) TLSOption {
+	return func(c *tls.Config) error {
+		if fn == nil {
+			return errors.New("privatetls: peer certificate verification function must not be nil")
+		}
+		c.VerifyPeerCertificate = fn
+		return nil
+	}
+}
+
+// WithPerClientConfig sets tls.Config.GetConfigForClient, which selects the
+// whole TLS configuration for each client hello, e.g. to require a higher
+// minimum version from clients advertising particular protocols. Returning a
+// nil configuration keeps the original one. As documented by crypto/tls, the
+// returned configuration must not set GetConfigForClient itself, since it is
+// ignored there; such configurations fail the handshake with an error.
+//
+// A GetConfigForClient set by an earlier option, e.g. WithSNIAllowList, runs
+// first: when it rejects the client, fn is not called, and when fn returns a
+// nil configuration, the one it selected is kept.
+func WithPerClientConfig(fn func(*tls.ClientHelloInfo) (*tls.Config, error)) TLSOption {
+	return func(c *tls.Config) error {
+		if fn == nil {
+			return errors.New("privatetls: per-client configuration function must not be nil")
+		}
+
+		prev := c.GetConfigForClient
+
+		c.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			var prevCfg *tls.Config
+			if prev != nil {
+				var err error
+				if prevCfg, err = prev(hello); err != nil {
+					return nil, err
+				}
+			}
+
+			cfg, err := fn(hello)
+			if err != nil {
+				return nil, err
+			}
+
+			if cfg == nil {
+				return prevCfg, nil
+			}
+
+			if cfg.GetConfigForClient != nil {
+				return nil, errors.New("privatetls: per-client configuration must not set GetConfigForClient")
+			}
+			return cfg, nil
+		}
+		return nil
+	}
+}
+
+// Reject values that are not TLS protocol versions
+func checkTLSVersion(v uint16) error {
+	switch v {
+	case tls.VersionTLS10, tls.VersionTLS11, tls.VersionTLS12, tls.VersionTLS13:
+		return nil
+	default:
+		return fmt.Errorf("privatetls: unknown TLS version 0x%04x", v)
+	}
+}
+
+// Report whether crypto/tls implements the cipher suite
+func isKnownCipherSuite(id uint16) bool {
+	for _, list := range [][]*tls.CipherSuite{tls.CipherSuites(), tls.InsecureCipherSuites()} {
+		for _, s := range list {
+			if s.ID == id {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,255 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNewTLSConfig(t *testing.T) {
+	cert, err := NewCert(WithEd25519())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	suites := []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}
+	curves := []tls.CurveID{tls.X25519}
+
+	cfg, err := NewTLSConfig(
+		WithCertificates(cert),
+		WithMinVersion(tls.VersionTLS12),
+		WithMaxVersion(tls.VersionTLS13),
+		WithCipherSuites(suites),
+		WithCurves(curves),
+		WithSessionTicketsDisabled(),
+		WithClientAuth(tls.RequestClientCert),
+	)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if len(cfg.Certificates) != 1 || cfg.MinVersion != tls.VersionTLS12 || cfg.MaxVersion != tls.VersionTLS13 {
+		t.Errorf("Unexpected certificates or versions: %d %x %x\n", len(cfg.Certificates), cfg.MinVersion, cfg.MaxVersion)
+	}
+
+	if len(cfg.CipherSuites) != 1 || len(cfg.CurvePreferences) != 1 {
+		t.Errorf("Unexpected cipher suites or curves: %v %v\n", cfg.CipherSuites, cfg.CurvePreferences)
+	}
+
+	if !cfg.SessionTicketsDisabled || cfg.ClientAuth != tls.RequestClientCert {
+		t.Errorf("Unexpected session tickets or client auth: %v %v\n", cfg.SessionTicketsDisabled, cfg.ClientAuth)
+	}
+}
+
+func TestNewTLSConfigGeneratesCertificate(t *testing.T) {
+	cfg, err := NewTLSConfig()
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if len(cfg.Certificates) != 1 {
+		t.Errorf("Expected a generated certificate, got %d\n", len(cfg.Certificates))
+	}
+}
+
+func TestWithTLS13Only(t *testing.T) {
+	cfg, err := NewTLSConfig(WithTLS13Only())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if cfg.MinVersion != tls.VersionTLS13 {
+		t.Errorf("Minimum version is %s, expected TLS 1.3\n", tls.VersionName(cfg.MinVersion))
+	}
+}
+
+func TestWithForwardSecrecyRequired(t *testing.T) {
+	cfg, err := NewTLSConfig(WithForwardSecrecyRequired())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if cfg.MinVersion != tls.VersionTLS12 || len(cfg.CipherSuites) == 0 {
+		t.Errorf("Unexpected minimum version or cipher suites: %x %v\n", cfg.MinVersion, cfg.CipherSuites)
+	}
+
+	for _, id := range cfg.CipherSuites {
+		if name := tls.CipherSuiteName(id); !strings.HasPrefix(name, "TLS_ECDHE_") {
+			t.Errorf("Unexpected cipher suite without forward secrecy: %s\n", name)
+		}
+	}
+
+	// A higher minimum version is kept
+	cfg, err = NewTLSConfig(WithTLS13Only(), WithForwardSecrecyRequired())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if cfg.MinVersion != tls.VersionTLS13 {
+		t.Errorf("Unexpected minimum version: %x\n", cfg.MinVersion)
+	}
+}
+
+func TestWithALPN(t *testing.T) {
+	cfg, err := NewTLSConfig(WithALPN("h2", "http/1.1"))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if len(cfg.NextProtos) != 2 || cfg.NextProtos[0] != "h2" || cfg.NextProtos[1] != "http/1.1" {
+		t.Errorf("Unexpected ALPN protocols: %v\n", cfg.NextProtos)
+	}
+
+	if _, err := NewTLSConfig(WithALPN("")); err == nil {
+		t.Error("Expected an error for an empty protocol")
+	}
+}
+
+func TestWithRenegotiationPolicy(t *testing.T) {
+	cfg, err := NewTLSConfig(WithRenegotiationPolicy(tls.RenegotiateOnceAsClient))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if cfg.Renegotiation != tls.RenegotiateOnceAsClient {
+		t.Errorf("Unexpected renegotiation policy: %d\n", cfg.Renegotiation)
+	}
+}
+
+func TestStartHTTPSListenerTLS13(t *testing.T) {
+	addr := freeAddr(t)
+
+	go StartHTTPSListenerTLS13(addr)
+
+	waitForListener(t, addr)
+
+	conn, err := tls.Dial("tcp", addr, &tls.Config{InsecureSkipVerify: true, MaxVersion: tls.VersionTLS12})
+	if err == nil {
+		conn.Close()
+		t.Error("Expected a TLS 1.2 connection to be rejected")
+	}
+
+	conn, err = tls.Dial("tcp", addr, &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	defer conn.Close()
+
+	if v := conn.ConnectionState().Version; v != tls.VersionTLS13 {
+		t.Errorf("Negotiated %s, expected TLS 1.3\n", tls.VersionName(v))
+	}
+}
+
+func TestWithPerClientConfig(t *testing.T) {
+	strict, err := NewTLSConfig(WithTLS13Only())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	cfg, err := NewTLSConfig(WithPerClientConfig(func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		if hello.ServerName == "strict.internal" {
+			return strict, nil
+		}
+		return nil, nil
+	}))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if got, _ := cfg.GetConfigForClient(&tls.ClientHelloInfo{ServerName: "strict.internal"}); got != strict {
+		t.Error("Expected the strict configuration to be selected")
+	}
+
+	if got, _ := cfg.GetConfigForClient(&tls.ClientHelloInfo{}); got != nil {
+		t.Error("Expected the original configuration to be kept")
+	}
+
+	if _, err := NewTLSConfig(WithPerClientConfig(nil)); err == nil {
+		t.Error("Expected an error for a nil function")
+	}
+
+	nested, _ := NewTLSConfig(WithPerClientConfig(func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		return cfg, nil
+	}))
+
+	if _, err := nested.GetConfigForClient(&tls.ClientHelloInfo{}); err == nil {
+		t.Error("Expected an error for a returned configuration with GetConfigForClient")
+	}
+}
+
+func TestWithVerifyPeerCertificate(t *testing.T) {
+	clientCert, err := NewCert(WithEd25519())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	var received int
+	cfg, err := NewTLSConfig(WithClientAuth(tls.RequireAnyClientCert),
+		WithVerifyPeerCertificate(func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+			received = len(rawCerts)
+			if verifiedChains != nil {
+				return errors.New("unexpected verified chains")
+			}
+			return errors.New("rejected")
+		}))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	clientConfig := &tls.Config{InsecureSkipVerify: true, Certificates: []tls.Certificate{clientCert}}
+
+	if err := handshake(cfg, clientConfig); err == nil || !strings.Contains(err.Error(), "rejected") {
+		t.Errorf("Expected the callback to reject the handshake, got: %v\n", err)
+	}
+
+	if received != 1 {
+		t.Errorf("Unexpected number of certificates received: %d\n", received)
+	}
+
+	if _, err := NewTLSConfig(WithVerifyPeerCertificate(nil)); err == nil {
+		t.Error("Expected an error for a nil function")
+	}
+}
+
+func TestNewTLSConfigInvalid(t *testing.T) {
+	invalid := [][]TLSOption{
+		{WithMinVersion(0x1234)},
+		{WithMinVersion(tls.VersionTLS13), WithMaxVersion(tls.VersionTLS12)},
+		{WithCipherSuites([]uint16{0xffff})},
+		{WithClientAuth(tls.ClientAuthType(42))},
+		{WithRenegotiationPolicy(tls.RenegotiationSupport(42))},
+	}
+
+	for i, opts := range invalid {
+		if _, err := NewTLSConfig(opts...); err == nil {
+			t.Errorf("Expected an error for options set %d\n", i)
+		}
+	}
+}
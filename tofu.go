@@ -0,0 +1,138 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+)
+
+// TOFUStore authenticates servers by trusting on first use, like SSH does
+// with known_hosts: the SHA-256 fingerprint of the first certificate a server
+// presents is recorded in a JSON file, and later connections fail if the
+// server presents another certificate. It suits private services for which a
+// CA is overkill. A TOFUStore is safe for concurrent use.
+type TOFUStore struct {
+	path string
+
+	mu           sync.Mutex
+	fingerprints map[string]string // By host:port
+}
+
+// NewTOFUStore loads the fingerprints recorded in the file at storePath, or
+// starts empty if the file does not exist yet. The file is written whenever a
+// new server is seen.
+func NewTOFUStore(storePath string) (*TOFUStore, error) {
+	s := &TOFUStore{
+		path:         storePath,
+		fingerprints: make(map[string]string),
+	}
+
+	data, err := os.ReadFile(storePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &s.fingerprints); err != nil {
+		return nil, fmt.Errorf("privatetls: parsing %s: %w", storePath, err)
+	}
+
+	return s, nil
+}
+
+// TLSDialContext connects to the address like tls.Dialer.DialContext, as a
+// drop-in for tls.Dial, e.g. as http.Transport.DialTLSContext. Instead of
+// verifying the certificate chain, the handshake fails unless the server
+// certificate matches the one recorded for the address, or the address is
+// new, in which case its certificate is recorded.
+func (s *TOFUStore) TLSDialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &tls.Dialer{
+		Config: &tls.Config{
+			ServerName: host,
+			// The certificate is checked against the store rather than a CA
+			InsecureSkipVerify: true,
+			VerifyConnection: func(cs tls.ConnectionState) error {
+				if len(cs.PeerCertificates) == 0 {
+					return errEmptyChain
+				}
+				return s.verify(addr, FingerprintSHA256(cs.PeerCertificates[0]))
+			},
+		},
+	}
+
+	return d.DialContext(ctx, network, addr)
+}
+
+// Forget removes the fingerprint recorded for the address, so that the next
+// connection trusts the certificate it is presented, e.g. after the server
+// legitimately replaced its certificate.
+func (s *TOFUStore) Forget(addr string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.fingerprints, strings.ToLower(addr))
+
+	return s.save()
+}
+
+// Match the fingerprint against the recorded one, recording it if there is none
+func (s *TOFUStore) verify(addr, fingerprint string) error {
+	key := strings.ToLower(addr)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	known, ok := s.fingerprints[key]
+	if !ok {
+		s.fingerprints[key] = fingerprint
+		if err := s.save(); err != nil {
+			delete(s.fingerprints, key)
+			return fmt.Errorf("privatetls: recording the certificate of %s: %w", addr, err)
+		}
+		return nil
+	}
+
+	if known != fingerprint {
+		return fmt.Errorf("privatetls: certificate of %s has changed, fingerprint is %s but %s was recorded",
+			addr, fingerprint, known)
+	}
+
+	return nil
+}
+
+// Write the fingerprints to the file
+func (s *TOFUStore) save() error {
+	data, err := json.MarshalIndent(s.fingerprints, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(s.path, data, certFileMode)
+}
@@ -0,0 +1,124 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"context"
+	"crypto/tls"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestTOFUStore(t *testing.T) {
+	first, err := NewCert(WithEd25519())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	second, err := NewCert(WithEd25519())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	// Serve whichever certificate is current
+	var current atomic.Pointer[tls.Certificate]
+	current.Store(&first)
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return current.Load(), nil
+		},
+	})
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	defer l.Close()
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				conn.(*tls.Conn).Handshake()
+				conn.Close()
+			}()
+		}
+	}()
+
+	storePath := filepath.Join(t.TempDir(), "known_hosts.json")
+	addr := l.Addr().String()
+	dial := func(s *TOFUStore) error {
+		conn, err := s.TLSDialContext(context.Background(), "tcp", addr)
+		if err == nil {
+			conn.Close()
+		}
+		return err
+	}
+
+	s, err := NewTOFUStore(storePath)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := dial(s); err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+	}
+
+	// The recorded fingerprint persists across stores
+	reloaded, err := NewTOFUStore(storePath)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	current.Store(&second)
+
+	if err := dial(reloaded); err == nil || !strings.Contains(err.Error(), "has changed") {
+		t.Errorf("Expected an error for a changed certificate, got: %v\n", err)
+	}
+
+	if err := reloaded.Forget(addr); err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if err := dial(reloaded); err != nil {
+		t.Errorf("Unexpected error after forgetting the address: %v\n", err)
+	}
+}
+
+func TestNewTOFUStoreInvalid(t *testing.T) {
+	storePath := filepath.Join(t.TempDir(), "known_hosts.json")
+
+	if err := writeFileAtomic(storePath, []byte("not json"), certFileMode); err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	if _, err := NewTOFUStore(storePath); err == nil {
+		t.Error("Expected an error for an invalid file")
+	}
+
+	if _, err := (&TOFUStore{}).TLSDialContext(context.Background(), "tcp", "no port"); err == nil {
+		t.Error("Expected an error for an address without a port")
+	}
+}
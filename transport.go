@@ -0,0 +1,45 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+)
+
+// NewTLSTransport returns a transport for clients of servers using
+// certificates generated by this package. It trusts caCert, e.g. a
+// self-signed server certificate or the CA that issued it, or the system
+// roots if caCert is nil, and presents clientCert to servers requesting client
+// certificates, unless it is nil. The timeouts, proxy settings and HTTP/2
+// support are those of http.DefaultTransport.
+func NewTLSTransport(caCert *x509.Certificate, clientCert *tls.Certificate) *http.Transport {
+	cfg := &tls.Config{}
+
+	if caCert != nil {
+		cfg.RootCAs = x509.NewCertPool()
+		cfg.RootCAs.AddCert(caCert)
+	}
+
+	if clientCert != nil {
+		cfg.Certificates = []tls.Certificate{*clientCert}
+	}
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = cfg
+	t.ForceAttemptHTTP2 = true
+
+	return t
+}
@@ -0,0 +1,69 @@
+// Copyright © 2019 Igor Bondarenko <ibondare@protonmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privatetls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewTLSTransport(t *testing.T) {
+	serverCert, clientCert, caCert, err := NewMTLSPair([]Option{WithEd25519()}, []Option{WithCommonName("worker")})
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(caCert)
+
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s", r.Proto, r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	s.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+	s.EnableHTTP2 = true
+	s.StartTLS()
+	defer s.Close()
+
+	client := &http.Client{Transport: NewTLSTransport(caCert, &clientCert)}
+
+	// The server certificate is issued for localhost
+	_, port, _ := net.SplitHostPort(s.Listener.Addr().String())
+	resp, err := client.Get("https://localhost:" + port)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	defer resp.Body.Close()
+
+	var proto, cn string
+	fmt.Fscan(resp.Body, &proto, &cn)
+
+	if proto != "HTTP/2.0" || cn != "worker" {
+		t.Errorf("Unexpected protocol or client identity: %s %s\n", proto, cn)
+	}
+
+	if tr := NewTLSTransport(nil, nil); tr.TLSClientConfig.RootCAs != nil || len(tr.TLSClientConfig.Certificates) != 0 {
+		t.Error("Expected the system roots and no client certificate")
+	}
+}